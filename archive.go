@@ -0,0 +1,157 @@
+package bls12_381_hd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// ErrSeedArchiveChecksum is returned when an encrypted seed archive fails the checksum,
+// which typically means the password is wrong.
+var ErrSeedArchiveChecksum = errors.New("seed archive checksum mismatch, wrong password?")
+
+var seedArchiveMagic = [4]byte{'B', 'H', 'D', 'S'}
+
+const (
+	seedArchiveVersion = 1
+
+	seedArchiveEncrypted = 1 << 0
+
+	// The scrypt cost of an archive is read from an untrusted header,
+	// so it is capped at the EIP-2335 defaults that MarshalSeedArchive writes.
+	seedArchiveMaxLogN = 18
+	seedArchiveMaxR    = scryptR
+	seedArchiveMaxP    = scryptP
+)
+
+// MarshalSeedArchive encodes the seed in the SeedArchive binary format, a versioned container that can be
+// recognized on disk. If password is not empty, the seed is encrypted with AES-128-CTR,
+// under a key derived with scrypt (EIP-2335 default parameters), and protected by a SHA256 checksum.
+//
+// Layout, with multi-byte integers in big-endian:
+//
+//	magic "BHDS" | version (1 byte) | flags (1 byte) | [encryption header] | seed length (2 bytes) | seed
+//
+// The encryption header, present if flag bit 0 is set:
+//
+//	log2(scrypt N) (1 byte) | scrypt r (1 byte) | scrypt p (1 byte) | salt (32 bytes) | iv (16 bytes) | checksum (32 bytes)
+//
+// UnmarshalSeedArchive rejects a scrypt cost above the defaults (log2 N = 18, r = 8, p = 1),
+// and seeds shorter than 32 bytes.
+func MarshalSeedArchive(seed Seed, password string) ([]byte, error) {
+	return marshalSeedArchive(seed, password, rand.Reader)
+}
+
+func marshalSeedArchive(seed Seed, password string, rng io.Reader) ([]byte, error) {
+	if len(seed) < 32 {
+		return nil, ErrSeedTooShort
+	}
+	if len(seed) > 0xffff {
+		return nil, errors.New("seed is too long")
+	}
+	var buf bytes.Buffer
+	buf.Write(seedArchiveMagic[:])
+	buf.WriteByte(seedArchiveVersion)
+	payload := []byte(seed)
+	if password == "" {
+		buf.WriteByte(0)
+	} else {
+		buf.WriteByte(seedArchiveEncrypted)
+		params, err := newScryptParams(rng)
+		if err != nil {
+			return nil, err
+		}
+		iv := make([]byte, 16)
+		if _, err := io.ReadFull(rng, iv); err != nil {
+			return nil, fmt.Errorf("failed to generate iv: %w", err)
+		}
+		dk, err := params.deriveKey([]byte(password))
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+		}
+		payload, err = aes128CTR(dk, iv, seed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt seed: %w", err)
+		}
+		sum := checksum(dk, payload)
+		buf.WriteByte(byte(bits.TrailingZeros(uint(params.N))))
+		buf.WriteByte(byte(params.R))
+		buf.WriteByte(byte(params.P))
+		buf.Write(params.Salt)
+		buf.Write(iv)
+		buf.Write(sum[:])
+	}
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(payload))))
+	buf.Write(payload)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalSeedArchive decodes a seed from the SeedArchive binary format, see MarshalSeedArchive.
+// The password is ignored if the archive is not encrypted.
+// ErrSeedArchiveChecksum is returned if the password is wrong.
+func UnmarshalSeedArchive(data []byte, password string) (Seed, error) {
+	in := bytes.NewReader(data)
+	var header [6]byte
+	if _, err := io.ReadFull(in, header[:]); err != nil {
+		return nil, errors.New("seed archive is too short")
+	}
+	if [4]byte(header[:4]) != seedArchiveMagic {
+		return nil, errors.New("not a seed archive")
+	}
+	if header[4] != seedArchiveVersion {
+		return nil, fmt.Errorf("unsupported seed archive version %d", header[4])
+	}
+	flags := header[5]
+	if flags&^seedArchiveEncrypted != 0 {
+		return nil, fmt.Errorf("unknown seed archive flags %08b", flags)
+	}
+	var enc [3 + 32 + 16 + 32]byte
+	if flags&seedArchiveEncrypted != 0 {
+		if _, err := io.ReadFull(in, enc[:]); err != nil {
+			return nil, errors.New("seed archive encryption header is too short")
+		}
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(in, length[:]); err != nil {
+		return nil, errors.New("seed archive is missing the seed length")
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(in, payload); err != nil {
+		return nil, errors.New("seed archive is truncated")
+	}
+	if in.Len() != 0 {
+		return nil, errors.New("unexpected trailing data after seed archive")
+	}
+	// AES-128-CTR preserves the length, so this checks the decrypted seed too, before any KDF work.
+	if len(payload) < 32 {
+		return nil, ErrSeedTooShort
+	}
+	if flags&seedArchiveEncrypted == 0 {
+		return Seed(payload), nil
+	}
+	logN, costR, costP := enc[0], enc[1], enc[2]
+	if logN < 1 || logN > seedArchiveMaxLogN || costR < 1 || costR > seedArchiveMaxR || costP < 1 || costP > seedArchiveMaxP {
+		return nil, fmt.Errorf("unsupported scrypt cost N=2**%d, r=%d, p=%d, expected at most N=2**%d, r=%d, p=%d",
+			logN, costR, costP, seedArchiveMaxLogN, seedArchiveMaxR, seedArchiveMaxP)
+	}
+	params := &scryptParams{DKLen: scryptDKLen, N: 1 << logN, R: int(costR), P: int(costP), Salt: enc[3:35]}
+	iv := enc[35:51]
+	expectedSum := [32]byte(enc[51:83])
+	dk, err := params.deriveKey([]byte(password))
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive decryption key: %w", err)
+	}
+	sum := checksum(dk, payload)
+	if !ctEqual32(&sum, &expectedSum) {
+		return nil, ErrSeedArchiveChecksum
+	}
+	seed, err := aes128CTR(dk, iv, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt seed: %w", err)
+	}
+	return Seed(seed), nil
+}
@@ -0,0 +1,90 @@
+package bls12_381_hd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSeedArchive(t *testing.T) {
+	seed := make(Seed, 64)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	t.Run("plain", func(t *testing.T) {
+		data, err := MarshalSeedArchive(seed, "")
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		got, err := UnmarshalSeedArchive(data, "")
+		if err != nil {
+			t.Fatalf("failed to unmarshal: %v", err)
+		}
+		if !bytes.Equal(got, seed) {
+			t.Fatal("seed changed in round-trip")
+		}
+	})
+	t.Run("encrypted", func(t *testing.T) {
+		data, err := MarshalSeedArchive(seed, "testpassword")
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		if bytes.Contains(data, seed[:16]) {
+			t.Fatal("encrypted archive contains plain seed bytes")
+		}
+		got, err := UnmarshalSeedArchive(data, "testpassword")
+		if err != nil {
+			t.Fatalf("failed to unmarshal: %v", err)
+		}
+		if !bytes.Equal(got, seed) {
+			t.Fatal("seed changed in round-trip")
+		}
+		if _, err := UnmarshalSeedArchive(data, "wrongpassword"); !errors.Is(err, ErrSeedArchiveChecksum) {
+			t.Fatalf("expected checksum error for wrong password, got %v", err)
+		}
+	})
+	t.Run("bad_magic", func(t *testing.T) {
+		data, err := MarshalSeedArchive(seed, "")
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		data[0] = 'X'
+		if _, err := UnmarshalSeedArchive(data, ""); err == nil {
+			t.Fatal("expected bad magic to be rejected")
+		}
+	})
+	t.Run("truncated", func(t *testing.T) {
+		data, err := MarshalSeedArchive(seed, "")
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		if _, err := UnmarshalSeedArchive(data[:len(data)-1], ""); err == nil {
+			t.Fatal("expected truncated archive to be rejected")
+		}
+	})
+	t.Run("short_seed", func(t *testing.T) {
+		data, err := MarshalSeedArchive(seed[:32], "")
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		// shorten the seed length and drop the last seed byte
+		data[len(data)-33] = 31
+		if _, err := UnmarshalSeedArchive(data[:len(data)-1], ""); !errors.Is(err, ErrSeedTooShort) {
+			t.Fatalf("expected short seed to be rejected, got %v", err)
+		}
+	})
+	t.Run("scrypt_cost", func(t *testing.T) {
+		data, err := MarshalSeedArchive(seed, "testpassword")
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		// the scrypt cost bytes follow the magic, version and flags
+		for i, v := range []byte{31, 255, 255} {
+			bad := bytes.Clone(data)
+			bad[6+i] = v
+			if _, err := UnmarshalSeedArchive(bad, "testpassword"); err == nil || errors.Is(err, ErrSeedArchiveChecksum) {
+				t.Fatalf("expected scrypt cost byte %d = %d to be rejected before key derivation, got %v", i, v, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,252 @@
+package bls12_381_hd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// BatchJob derives the keys for a range of indices, substituted into a path template,
+// and persists its progress, so an interrupted job can resume without redoing work.
+type BatchJob struct {
+	// Seed is the seed to derive keys from.
+	Seed []byte
+	// Template is a path with a {} placeholder for the index, see ExpandTemplate.
+	Template string
+	// Start is the first index to derive, End is the index after the last.
+	Start, End uint32
+	// State persists progress, as one JSON record per completed index.
+	// Only the next index, the seed checksum, the template and the range are stored, no secrets.
+	// Resuming with a different seed, template or range is rejected.
+	State io.ReadWriter
+}
+
+type batchState struct {
+	Next         uint32 `json:"next"`
+	SeedChecksum string `json:"seed_checksum"`
+	Template     string `json:"template"`
+	Start        uint32 `json:"start"`
+	End          uint32 `json:"end"`
+}
+
+// next returns the next index to derive, based on the last record in the state, if any.
+func (j *BatchJob) next() (uint32, error) {
+	next := j.Start
+	scanner := bufio.NewScanner(j.State)
+	for scanner.Scan() {
+		var st batchState
+		if err := json.Unmarshal(scanner.Bytes(), &st); err != nil {
+			return 0, fmt.Errorf("failed to decode batch state: %w", err)
+		}
+		if st.SeedChecksum != SeedChecksum(j.Seed) {
+			return 0, errors.New("batch state belongs to a different seed")
+		}
+		if st.Template != j.Template || st.Start != j.Start || st.End != j.End {
+			return 0, fmt.Errorf("batch state belongs to a different job: template %q, range [%d, %d)", st.Template, st.Start, st.End)
+		}
+		next = st.Next
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read batch state: %w", err)
+	}
+	if next < j.Start || next > j.End {
+		return 0, fmt.Errorf("batch state index %d is outside of range [%d, %d)", next, j.Start, j.End)
+	}
+	return next, nil
+}
+
+// Run derives the keys that were not completed yet, in order, and passes each to emit.
+// Once emit returns without error, the index is recorded as completed in the state.
+// Run stops at the first error, or when ctx is done; a later Run resumes after the last completed index.
+func (j *BatchJob) Run(ctx context.Context, emit func(index uint32, key *[32]byte) error) error {
+	if j.Start > j.End {
+		return fmt.Errorf("invalid range [%d, %d)", j.Start, j.End)
+	}
+	next, err := j.next()
+	if err != nil {
+		return err
+	}
+	checksum := SeedChecksum(j.Seed)
+	for i := next; i < j.End; i++ {
+		path, err := ExpandTemplate(j.Template, i)
+		if err != nil {
+			return err
+		}
+		key, err := SecretKeyFromHDContext(ctx, j.Seed, path)
+		if err != nil {
+			return fmt.Errorf("failed to derive key %d: %w", i, err)
+		}
+		if err := emit(i, key); err != nil {
+			return fmt.Errorf("failed to emit key %d: %w", i, err)
+		}
+		record, err := json.Marshal(batchState{Next: i + 1, SeedChecksum: checksum, Template: j.Template, Start: j.Start, End: j.End})
+		if err != nil {
+			return err
+		}
+		if _, err := j.State.Write(append(record, '\n')); err != nil {
+			return fmt.Errorf("failed to persist batch state: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeriveRangeWithProgress derives the children 0 ... count-1 of the key at basePath.
+// The key at basePath is derived only once.
+//
+// After each derived key, the number of completed keys is sent to progress, if not nil.
+// Sends never block: a value is dropped if the channel is not ready.
+// The channel is not closed; no more values are sent once the function returns.
+func DeriveRangeWithProgress(seed []byte, basePath string, count int, progress chan<- int) ([]*SK, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("invalid count %d", count)
+	}
+	if uint64(count) > 1<<32 {
+		return nil, fmt.Errorf("count %d exceeds index range", count)
+	}
+	if len(seed) < 32 {
+		return nil, ErrSeedTooShort
+	}
+	indices, err := ParsePath(basePath)
+	if err != nil {
+		return nil, err
+	}
+	baseSK, err := deriveSK(context.Background(), seed, indices, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer baseSK.Wipe()
+	out := make([]*SK, count)
+	for i := 0; i < count; i++ {
+		sk, err := DeriveChildSK(baseSK, uint32(i))
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive child %d: %w", i, err)
+		}
+		out[i] = sk
+		if progress != nil {
+			select {
+			case progress <- i + 1:
+			default:
+			}
+		}
+	}
+	return out, nil
+}
+
+// pathNode is a node in the prefix tree of SecretKeysFromHD, caching its derived key.
+type pathNode struct {
+	sk       *SK
+	children map[uint32]*pathNode
+}
+
+// wipe wipes the keys of the node and all its descendants.
+func (n *pathNode) wipe() {
+	n.sk.Wipe()
+	for _, child := range n.children {
+		child.wipe()
+	}
+}
+
+// SecretKeysFromHD derives the keys of multiple paths, like SecretKeyFromHD,
+// but derives every shared prefix of the paths only once.
+// E.g. m/12381/3600/0/0 and m/12381/3600/1/0 share the derivation of m/12381/3600.
+// The output is in the same order as the paths.
+func SecretKeysFromHD(seed []byte, paths []string) ([]*[32]byte, error) {
+	return SecretKeysFromHDContext(context.Background(), seed, paths)
+}
+
+// SecretKeysFromHDContext is like SecretKeysFromHD, but checks ctx before every child derivation,
+// so a large batch can be aborted. The returned error wraps the context error.
+func SecretKeysFromHDContext(ctx context.Context, seed []byte, paths []string) ([]*[32]byte, error) {
+	if len(seed) < 32 {
+		return nil, ErrSeedTooShort
+	}
+	parsed := make([]Path, len(paths))
+	for i, path := range paths {
+		p, err := ParsePath(path)
+		if err != nil {
+			return nil, fmt.Errorf("path %d: %w", i, err)
+		}
+		parsed[i] = p
+	}
+	masterSK, err := DeriveMasterSK(seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive secret key from master node: %w", err)
+	}
+	root := &pathNode{sk: masterSK}
+	// every cached key is an intermediate: only the encoded keys are returned
+	defer root.wipe()
+	out := make([]*[32]byte, len(paths))
+	for i, p := range parsed {
+		node := root
+		for j, index := range p {
+			child, ok := node.children[index]
+			if !ok {
+				if err := checkContext(ctx, j+1); err != nil {
+					return nil, fmt.Errorf("path %d: %w", i, err)
+				}
+				sk, err := DeriveChildSK(node.sk, index)
+				if err != nil {
+					return nil, fmt.Errorf("path %d: failed to derive secret key from child node at segment %d, index %d: %w", i, j+1, index, err)
+				}
+				child = &pathNode{sk: sk}
+				if node.children == nil {
+					node.children = make(map[uint32]*pathNode)
+				}
+				node.children[index] = child
+			}
+			node = child
+		}
+		key, err := I2OSP32Checked((*big.Int)(node.sk))
+		if err != nil {
+			return nil, fmt.Errorf("path %d: failed to encode derived key: %w", i, err)
+		}
+		out[i] = &key
+	}
+	return out, nil
+}
+
+// SecretKeysFromTemplate derives the keys of every path of a path template with a range segment,
+// see ExpandPathRange, in the same order. Like SecretKeysFromHD, shared prefixes are derived only once.
+func SecretKeysFromTemplate(seed []byte, template string) ([]*[32]byte, error) {
+	paths, err := ExpandPathRange(template)
+	if err != nil {
+		return nil, err
+	}
+	return SecretKeysFromHD(seed, paths)
+}
+
+// SigningKeys derives the eth2 signing keys of the accounts start ... start+count-1, in order,
+// see SigningKeyPath. The shared m/12381/3600 prefix is derived only once.
+func SigningKeys(seed []byte, start, count uint32) ([]*[32]byte, error) {
+	if uint64(start)+uint64(count) > 1<<32 {
+		return nil, fmt.Errorf("account range [%d, %d+%d) exceeds index range", start, start, count)
+	}
+	if len(seed) < 32 {
+		return nil, ErrSeedTooShort
+	}
+	baseSK, err := deriveSK(context.Background(), seed, Path{Eth2Purpose, Eth2CoinType}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer baseSK.Wipe()
+	out := make([]*[32]byte, count)
+	for i := range out {
+		account := start + uint32(i)
+		// account, then the withdrawal key 0, then the signing key 0
+		sk, err := DeriveChildSKPath(baseSK, account, 0, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive signing key of account %d: %w", account, err)
+		}
+		key, err := I2OSP32Checked((*big.Int)(sk))
+		sk.Wipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode signing key of account %d: %w", account, err)
+		}
+		out[i] = &key
+	}
+	return out, nil
+}
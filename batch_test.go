@@ -0,0 +1,241 @@
+package bls12_381_hd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestBatchJobResume(t *testing.T) {
+	seed := make([]byte, 32)
+	var state bytes.Buffer
+	job := &BatchJob{Seed: seed, Template: "m/{}/0", Start: 2, End: 8, State: &state}
+	got := make(map[uint32][32]byte)
+	crash := errors.New("crash")
+	err := job.Run(context.Background(), func(index uint32, key *[32]byte) error {
+		if index == 5 {
+			return crash
+		}
+		got[index] = *key
+		return nil
+	})
+	if !errors.Is(err, crash) {
+		t.Fatalf("expected interrupted run, got %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 keys before the crash, got %d", len(got))
+	}
+	firstKey := got[2]
+	if bytes.Contains(state.Bytes(), firstKey[:]) {
+		t.Fatal("state contains secret key material")
+	}
+	err = job.Run(context.Background(), func(index uint32, key *[32]byte) error {
+		if _, ok := got[index]; ok {
+			return fmt.Errorf("index %d derived twice", index)
+		}
+		got[index] = *key
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to resume: %v", err)
+	}
+	for i := uint32(2); i < 8; i++ {
+		expected, err := SecretKeyFromHD(seed, fmt.Sprintf("m/%d/0", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key, ok := got[i]; !ok || key != *expected {
+			t.Fatalf("missing or wrong key for index %d", i)
+		}
+	}
+	if len(got) != 6 {
+		t.Fatalf("expected 6 keys, got %d", len(got))
+	}
+
+	otherSeed := make([]byte, 32)
+	otherSeed[0] = 1
+	state.Reset()
+	state.WriteString(`{"next":3,"seed_checksum":"` + SeedChecksum(seed) + `","template":"m/{}/0","start":2,"end":8}` + "\n")
+	other := &BatchJob{Seed: otherSeed, Template: "m/{}/0", Start: 2, End: 8, State: &state}
+	if err := other.Run(context.Background(), func(uint32, *[32]byte) error { return nil }); err == nil {
+		t.Fatal("expected state of a different seed to be rejected")
+	}
+}
+
+func TestBatchJobResumeChangedJob(t *testing.T) {
+	seed := make([]byte, 32)
+	var state bytes.Buffer
+	job := &BatchJob{Seed: seed, Template: "m/{}/0", Start: 2, End: 8, State: &state}
+	if err := job.Run(context.Background(), func(index uint32, key *[32]byte) error {
+		if index == 5 {
+			return errors.New("crash")
+		}
+		return nil
+	}); err == nil {
+		t.Fatal("expected interrupted run")
+	}
+	saved := state.String()
+	for _, changed := range []*BatchJob{
+		{Seed: seed, Template: "m/12381/{}/0", Start: 2, End: 8},
+		{Seed: seed, Template: "m/{}/0", Start: 0, End: 8},
+		{Seed: seed, Template: "m/{}/0", Start: 2, End: 9},
+	} {
+		changed.State = bytes.NewBufferString(saved)
+		err := changed.Run(context.Background(), func(index uint32, key *[32]byte) error {
+			return fmt.Errorf("unexpected key %d", index)
+		})
+		if err == nil || !strings.Contains(err.Error(), "different job") {
+			t.Fatalf("expected state of job %q [%d, %d) to be rejected, got %v", changed.Template, changed.Start, changed.End, err)
+		}
+	}
+}
+
+func TestDeriveRangeWithProgress(t *testing.T) {
+	seed := make([]byte, 32)
+	progress := make(chan int, 5)
+	keys, err := DeriveRangeWithProgress(seed, "m/12381", 5, progress)
+	if err != nil {
+		t.Fatalf("failed to derive range: %v", err)
+	}
+	close(progress)
+	last := 0
+	for p := range progress {
+		if p <= last {
+			t.Fatalf("progress not monotonic: %d after %d", p, last)
+		}
+		last = p
+	}
+	if last != 5 {
+		t.Fatalf("expected progress to reach 5, got %d", last)
+	}
+	for i, key := range keys {
+		expected, err := SecretKeyFromHD(seed, fmt.Sprintf("m/12381/%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if I2OSP32((*big.Int)(key)) != *expected {
+			t.Fatalf("key %d differs", i)
+		}
+	}
+	// an unbuffered channel without receiver must not block derivation
+	if _, err := DeriveRangeWithProgress(seed, "m", 2, make(chan int)); err != nil {
+		t.Fatalf("failed to derive range: %v", err)
+	}
+}
+
+func TestSecretKeysFromHD(t *testing.T) {
+	seed := make([]byte, 32)
+	paths := []string{"m/12381/3600/1/0", "m", "m/12381/3600/0/0", "m/12381/3600/1/0", "m/12381"}
+	keys, err := SecretKeysFromHD(seed, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != len(paths) {
+		t.Fatalf("expected %d keys, got %d", len(paths), len(keys))
+	}
+	for i, path := range paths {
+		expected, err := SecretKeyFromHD(seed, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *keys[i] != *expected {
+			t.Fatalf("key %d for path %s differs", i, path)
+		}
+	}
+	if _, err := SecretKeysFromHD(seed, []string{"m/0", "m/x"}); err == nil {
+		t.Fatal("expected invalid path to be rejected")
+	}
+}
+
+func TestSecretKeysFromHDContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SecretKeysFromHDContext(ctx, make([]byte, 32), accountPaths(3)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+}
+
+func TestSecretKeysFromTemplate(t *testing.T) {
+	seed := make([]byte, 32)
+	keys, err := SecretKeysFromTemplate(seed, "m/12381/3600/{2..4}/0/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := SigningKeys(seed, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != len(expected) {
+		t.Fatalf("expected %d keys, got %d", len(expected), len(keys))
+	}
+	for i := range keys {
+		if *keys[i] != *expected[i] {
+			t.Fatalf("key %d differs", i)
+		}
+	}
+	if _, err := SecretKeysFromTemplate(seed, "m/12381/3600/{4..2}/0/0"); err == nil {
+		t.Fatal("expected reversed range to be rejected")
+	}
+}
+
+func TestSigningKeys(t *testing.T) {
+	seed := make([]byte, 32)
+	keys, err := SigningKeys(seed, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 keys, got %d", len(keys))
+	}
+	for i, key := range keys {
+		expected, err := DeriveSigningKey(seed, uint32(5+i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *key != *expected {
+			t.Fatalf("key %d differs", i)
+		}
+	}
+	if _, err := SigningKeys(seed, 1<<32-1, 2); err == nil {
+		t.Fatal("expected overflowing range to be rejected")
+	}
+	if keys, err := SigningKeys(seed, 1<<32-1, 1); err != nil || len(keys) != 1 {
+		t.Fatalf("expected last account to be derivable, got %v", err)
+	}
+}
+
+func accountPaths(n int) []string {
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("m/12381/3600/%d/0", i)
+	}
+	return paths
+}
+
+func BenchmarkSecretKeysFromHD(b *testing.B) {
+	seed := make([]byte, 32)
+	paths := accountPaths(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := SecretKeysFromHD(seed, paths); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSecretKeyFromHDLoop(b *testing.B) {
+	seed := make([]byte, 32)
+	paths := accountPaths(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range paths {
+			if _, err := SecretKeyFromHD(seed, path); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
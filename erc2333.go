@@ -1,35 +1,192 @@
 package bls12_381_hd
 
 import (
+	"bytes"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"math/big"
+	"sync"
+	"sync/atomic"
 
 	"golang.org/x/crypto/hkdf"
 )
 
 type IKM []byte
 
-func (v IKM) flipBits() (out IKM) {
-	out = make(IKM, len(v))
+// flipBitsInto writes the bitwise negation of v into dst, which must have the same length.
+func (v IKM) flipBitsInto(dst IKM) {
 	for i, b := range v {
-		out[i] = ^b
+		dst[i] = ^b
 	}
-	return out
+}
+
+// parentIKM returns IKM = I2OSP(parent_SK, 32).
+// The lamport construction expects an IKM of exactly 32 bytes,
+// so a parent SK that is nil, negative, or does not fit in 32 bytes is rejected.
+func parentIKM(parentSK *SK) ([32]byte, error) {
+	if parentSK == nil {
+		return [32]byte{}, errors.New("nil parent SK")
+	}
+	sk32, err := I2OSP32Checked((*big.Int)(parentSK))
+	if err != nil {
+		return sk32, fmt.Errorf("parent SK is not a 32 byte IKM: %w", err)
+	}
+	return sk32, nil
 }
 
 type Salt [4]byte
 
+// SaltFromIndex returns the salt of a child index, I2OSP(index, 4), as used by parent_SK_to_lamport_PK.
+func SaltFromIndex(index uint32) Salt {
+	return i2OSP4(index)
+}
+
+// Index returns the child index of the salt, the reverse of SaltFromIndex.
+func (s Salt) Index() uint32 {
+	return binary.BigEndian.Uint32(s[:])
+}
+
 type LamportSK [255][32]byte
 
+// Wipe overwrites all chunks of the lamport SK with zeroes.
+func (sk *LamportSK) Wipe() {
+	for i := range sk {
+		zero(sk[i][:])
+	}
+}
+
 type SK big.Int
 
+// Bytes returns the secret key as 32 byte big-endian integer, zero-padded.
+//
+// Bytes panics if the key is negative or does not fit in 32 bytes, like I2OSP32 panics on values
+// wider than 256 bits. Keys derived by this package are always in range;
+// check InRange first for keys from other sources, or use I2OSP32Checked.
+func (sk *SK) Bytes() [32]byte {
+	out, err := I2OSP32Checked((*big.Int)(sk))
+	if err != nil {
+		panic(fmt.Errorf("invalid secret key: %w", err))
+	}
+	return out
+}
+
+// InRange checks if the secret key is a valid BLS scalar, in the range [1, r).
+func (sk *SK) InRange() bool {
+	if sk == nil {
+		return false
+	}
+	v := (*big.Int)(sk)
+	return v.Sign() > 0 && v.Cmp(r) < 0
+}
+
+// EqualCT compares the 32 byte big-endian encodings of two secret keys in constant time.
+// Keys that are nil, negative or larger than 32 bytes are never equal.
+func (sk *SK) EqualCT(other *SK) bool {
+	if sk == nil || other == nil {
+		return false
+	}
+	a, errA := I2OSP32Checked((*big.Int)(sk))
+	b, errB := I2OSP32Checked((*big.Int)(other))
+	defer zero(a[:])
+	defer zero(b[:])
+	return errA == nil && errB == nil && ctEqual32(&a, &b)
+}
+
+// Hex returns the 32 byte big-endian encoding of the secret key in lowercase hex, without 0x prefix.
+// Like Bytes, it panics if the key is negative or does not fit in 32 bytes.
+func (sk *SK) Hex() string {
+	b := sk.Bytes()
+	defer zero(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// String returns the secret key as decimal integer, as in the ERC-2333 test vectors.
+func (sk *SK) String() string {
+	return (*big.Int)(sk).String()
+}
+
+// MarshalText encodes the secret key as 0x-prefixed 32 byte big-endian hex.
+func (sk *SK) MarshalText() ([]byte, error) {
+	if sk == nil {
+		return nil, ErrSKOutOfRange
+	}
+	if v := (*big.Int)(sk); v.Sign() < 0 || v.Cmp(r) >= 0 {
+		return nil, ErrSKOutOfRange
+	}
+	b, err := I2OSP32Checked((*big.Int)(sk))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSKOutOfRange, err)
+	}
+	defer zero(b[:])
+	out := make([]byte, 2+hex.EncodedLen(len(b)))
+	copy(out, "0x")
+	hex.Encode(out[2:], b[:])
+	return out, nil
+}
+
+// UnmarshalText decodes 0x-prefixed 32 byte big-endian hex, and checks the secret key is in the range [0, r).
+func (sk *SK) UnmarshalText(text []byte) error {
+	if !bytes.HasPrefix(text, []byte("0x")) {
+		return errors.New("secret key hex must have 0x prefix")
+	}
+	var b [32]byte
+	if hex.DecodedLen(len(text)-2) != 32 {
+		return fmt.Errorf("expected 32 byte secret key, got %d hex characters", len(text)-2)
+	}
+	if _, err := hex.Decode(b[:], text[2:]); err != nil {
+		return fmt.Errorf("invalid secret key hex: %w", err)
+	}
+	defer zero(b[:])
+	v := osToIP(b[:])
+	if v.Cmp(r) >= 0 {
+		return ErrSKOutOfRange
+	}
+	(*big.Int)(sk).Set(v)
+	return nil
+}
+
+// MarshalJSON encodes the secret key as JSON string, see MarshalText.
+func (sk *SK) MarshalJSON() ([]byte, error) {
+	text, err := sk.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON decodes the secret key from a JSON string, see UnmarshalText.
+func (sk *SK) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("secret key must be a JSON string: %w", err)
+	}
+	return sk.UnmarshalText([]byte(text))
+}
+
+// Wipe overwrites the secret key with zeroes, and sets it to 0.
+// This is best-effort: the Go runtime may have copied the value before.
+func (sk *SK) Wipe() {
+	wipeInt((*big.Int)(sk))
+}
+
 type CompressedLamportPK [32]byte
 
 type Seed []byte
 
+// Wipe overwrites the seed with zeroes.
+// This is best-effort: the Go runtime may have copied the value before.
+func (s Seed) Wipe() {
+	zero(s)
+}
+
 // IKMToLamportSK implements IKM_to_lamport_SK of ERC-2333.
 //
 // https://eips.ethereum.org/EIPS/eip-2333#ikm_to_lamport_sk
@@ -51,21 +208,81 @@ type Seed []byte
 //	L = K * 255 is the HKDF output size (in octets)
 //	"" is the empty string
 //	bytes_split is a function takes in an octet string and splits it into K-byte chunks which are returned as an array
+//
+// The lamport SK is secret material derived from the IKM:
+// callers that use the result directly should Wipe it when done.
 func IKMToLamportSK(ikm IKM, salt Salt) (*LamportSK, error) {
+	return ikmToLamportSK(ikm, salt[:])
+}
+
+// hkdfExpandFunc has the signature of hkdf.Expand, which every caller passes outside of tests,
+// where a reader that fails is injected instead.
+type hkdfExpandFunc func(h func() hash.Hash, prk, info []byte) io.Reader
+
+// ikmToLamportSK is IKMToLamportSK with a salt of any length.
+func ikmToLamportSK(ikm IKM, salt []byte) (*LamportSK, error) {
+	buf := getLamportBuf()
+	defer putLamportBuf(buf)
+	if err := ikmToLamportSKWithHash(ikm, salt, sha256.New, hkdf.Expand, buf[:]); err != nil {
+		return nil, err
+	}
+	var lamportSK LamportSK
+	for i := range lamportSK {
+		copy(lamportSK[i][:], lamportChunk(buf, i))
+	}
+	return &lamportSK, nil
+}
+
+// ikmToLamportSKWithHash is IKM_to_lamport_SK instantiated with the hash function h:
+// K is the digest size of h, and L = K * 255.
+// The OKM is read from expand into out in one go, which must be L bytes: chunk i is out[i*K:(i+1)*K].
+func ikmToLamportSKWithHash(ikm IKM, salt []byte, h func() hash.Hash, expand hkdfExpandFunc, out []byte) error {
+	k := h().Size()
+	if len(out) != 255*k {
+		return fmt.Errorf("expected %d byte lamport SK buffer, got %d", 255*k, len(out))
+	}
 	//0. PRK = HKDF-Extract(salt, IKM)
-	prk := hkdf.Extract(sha256.New, ikm, salt[:])
+	prk := hkdf.Extract(h, ikm, salt)
 	//1. OKM = HKDF-Expand(PRK, "" , L)
-	okm := hkdf.Expand(sha256.New, prk, nil)
+	okm := expand(h, prk, nil)
 	//2. lamport_SK = bytes_split(OKM, K)
-	var lamportSK LamportSK
-	for i := 0; i < 255; i++ {
-		_, err := io.ReadFull(okm, lamportSK[i][:])
-		if err != nil {
-			return nil, fmt.Errorf("failed to read OKM data for element %d: %w", i, err)
-		}
+	if _, err := io.ReadFull(okm, out); err != nil {
+		return fmt.Errorf("failed to read OKM data: %w", err)
 	}
 	//3. return lamport_SK
-	return &lamportSK, nil
+	return nil
+}
+
+// lamportBuf is a lamport SK of 255 SHA256 chunks, as one flat buffer.
+type lamportBuf [255 * 32]byte
+
+// lamportChunk returns chunk i of the lamport SK in buf.
+func lamportChunk(buf *lamportBuf, i int) []byte {
+	return buf[i*32 : (i+1)*32]
+}
+
+// lamportBufPool recycles the lamport SK buffers of parent_SK_to_lamport_PK,
+// to reduce GC pressure in bulk derivation. Buffers are zeroed before they are put back.
+var lamportBufPool atomic.Pointer[sync.Pool]
+
+func init() {
+	ResetPools()
+}
+
+// ResetPools drops all buffers held by the internal pools,
+// for tests and benchmarks that want deterministic allocation counts.
+func ResetPools() {
+	lamportBufPool.Store(&sync.Pool{New: func() any { return new(lamportBuf) }})
+}
+
+func getLamportBuf() *lamportBuf {
+	return lamportBufPool.Load().Get().(*lamportBuf)
+}
+
+// putLamportBuf zeroes the buffer, and returns it to the pool.
+func putLamportBuf(buf *lamportBuf) {
+	zero(buf[:])
+	lamportBufPool.Load().Put(buf)
 }
 
 // i2OSP4 runs I2OSP with 4 bytes result length.
@@ -80,16 +297,63 @@ func I2OSP32(v *big.Int) (out [32]byte) {
 	return out
 }
 
+// I2OSP32Checked is I2OSP32, but returns an error instead of panicking or dropping the sign,
+// if v is negative or does not fit in 32 bytes.
+func I2OSP32Checked(v *big.Int) (out [32]byte, err error) {
+	if v.Sign() < 0 {
+		return out, errors.New("cannot encode negative integer")
+	}
+	if v.BitLen() > 256 {
+		return out, fmt.Errorf("integer of %d bits does not fit in 32 bytes", v.BitLen())
+	}
+	return I2OSP32(v), nil
+}
+
 func osToIP(data []byte) *big.Int {
 	return new(big.Int).SetBytes(data)
 }
 
+// ctEqual32 compares two 32-byte values in constant time.
+// Use it instead of bytes.Equal for anything derived from secret material.
+func ctEqual32(a, b *[32]byte) bool {
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
+
 func SHA256(data []byte) []byte {
-	h := sha256.New()
+	return hashWith(sha256.New, data)
+}
+
+func hashWith(newHash func() hash.Hash, data []byte) []byte {
+	h := newHash()
 	h.Write(data)
 	return h.Sum(nil)
 }
 
+// ErrHookPanic is returned when an injected hash implementation panics.
+var ErrHookPanic = errors.New("injected hook panicked")
+
+// callHook runs fn, which calls into an injected implementation, and turns a panic into ErrHookPanic.
+func callHook(fn func()) (err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("%w: %v", ErrHookPanic, x)
+		}
+	}()
+	fn()
+	return nil
+}
+
+// hookHash is hashWith for an injected hash, recovering from panics, and checking the output size.
+func hookHash(newHash func() hash.Hash, data []byte) (out []byte, err error) {
+	if err := callHook(func() { out = hashWith(newHash, data) }); err != nil {
+		return nil, err
+	}
+	if len(out) != 32 {
+		return nil, fmt.Errorf("expected 32 byte hash output, got %d", len(out))
+	}
+	return out, nil
+}
+
 // ParentSKToLamportPK implements parent_SK_to_lamport_PK of ERC-2333.
 //
 // https://eips.ethereum.org/EIPS/eip-2333#parent_sk_to_lamport_pk
@@ -109,41 +373,309 @@ func SHA256(data []byte) []byte {
 //	flip_bits is a function that returns the bitwise negation of its input
 //	"" is the empty string
 //	a | b is the concatenation of a with b
+//
+// The IKM of the lamport construction must be exactly 32 bytes:
+// a parent SK that is nil, negative, or does not fit in 32 bytes is rejected with an error.
 func ParentSKToLamportPK(parentSK *SK, index uint32) (*CompressedLamportPK, error) {
 	//0. salt = I2OSP(index, 4)
 	salt := i2OSP4(index)
+	return parentSKToLamportPK(parentSK, salt[:], nil, false)
+}
+
+// ParentSKToLamportPKWithHash is ParentSKToLamportPK, with the SHA256 of the lamport chunks
+// and the final compression computed by hashes from newHash.
+// This allows a hardware or vectorized SHA256 implementation to be used for the bulk of the hashing.
+// The hash function must be SHA256, or the result will not match ERC-2333.
+func ParentSKToLamportPKWithHash(parentSK *SK, index uint32, newHash func() hash.Hash) (*CompressedLamportPK, error) {
+	var size int
+	if err := callHook(func() { size = newHash().Size() }); err != nil {
+		return nil, err
+	}
+	if size != 32 {
+		return nil, fmt.Errorf("expected 32 byte hash output, got %d", size)
+	}
+	//0. salt = I2OSP(index, 4)
+	salt := i2OSP4(index)
+	return parentSKToLamportPK(parentSK, salt[:], newHash, false)
+}
+
+// parentSKToLamportPK implements step 1 of parent_SK_to_lamport_PK, with a salt of any length,
+// followed by steps 2 to 9 with compressLamportPK. A nil newHash uses SHA256.
+func parentSKToLamportPK(parentSK *SK, salt []byte, newHash func() hash.Hash, lowMemory bool) (*CompressedLamportPK, error) {
 	//1. IKM = I2OSP(parent_SK, 32)
-	sk32 := I2OSP32((*big.Int)(parentSK))
-	ikm := IKM(sk32[:])
-	//2. lamport_0 = IKM_to_lamport_SK(IKM, salt)
-	lamport0, err := IKMToLamportSK(ikm, salt)
+	sk32, err := parentIKM(parentSK)
 	if err != nil {
-		return nil, fmt.Errorf("failed IKM_to_lamport_SK: %w", err)
+		return nil, err
 	}
-	//3. not_IKM = flip_bits(IKM)
-	notIKM := ikm.flipBits()
-	//4. lamport_1 = IKM_to_lamport_SK(not_IKM, salt)
-	lamport1, err := IKMToLamportSK(notIKM, salt)
+	defer zero(sk32[:])
+	return compressLamportPK(sk32[:], salt, newHash, lowMemory)
+}
+
+// CompressLamportPK implements steps 2 to 9 of parent_SK_to_lamport_PK of ERC-2333:
+// the compressed lamport PK of the given IKM and salt.
+// ParentSKToLamportPK is CompressLamportPK, with I2OSP(parent_SK, 32) as IKM, and I2OSP(index, 4) as salt.
+// The IKM must be exactly 32 bytes.
+func CompressLamportPK(ikm IKM, salt Salt) (*CompressedLamportPK, error) {
+	return compressLamportPK(ikm, salt[:], nil, false)
+}
+
+// compressLamportPK is CompressLamportPK with a salt of any length, and the given hash function.
+// A nil newHash uses SHA256 directly. A caller-supplied newHash is an injected implementation:
+// its panics are recovered, and its output size is checked.
+//
+// Each lamport SK is read into a pooled buffer before its chunks are hashed.
+// With lowMemory, every 32 byte chunk of HKDF output is hashed as soon as it is read instead,
+// so no lamport SK is held in memory, see DeriveOptions.LowMemory.
+func compressLamportPK(ikm IKM, salt []byte, newHash func() hash.Hash, lowMemory bool) (*CompressedLamportPK, error) {
+	if len(ikm) != 32 {
+		return nil, fmt.Errorf("expected 32 byte IKM, got %d", len(ikm))
+	}
+	// The intermediate values are all derived from the IKM, and the child SK is derived from the lamport PK:
+	// the lamport SK buffers, not_IKM, the chunk hashes and the compressor state are wiped after use.
 	//5. lamport_PK = ""
-	lamportPK := make([]byte, 0, 255*32*2)
+	// lamport_PK is not buffered: every chunk hash is written into the compressing hash right away.
+	// This only depends on the chunk hashes, so each lamport SK is hashed right after it is computed,
+	// combining steps 2 and 6, and steps 4 and 7.
+	var compressor hash.Hash
+	var appendChunk func(data []byte) error
+	if newHash == nil {
+		compressor = sha256.New()
+		defer compressor.Reset()
+		appendChunk = func(data []byte) error {
+			h := sha256.Sum256(data)
+			compressor.Write(h[:])
+			zero(h[:])
+			return nil
+		}
+	} else {
+		if err := callHook(func() { compressor = newHash() }); err != nil {
+			return nil, fmt.Errorf("failed to create lamport PK hash: %w", err)
+		}
+		// the hash is injected: a panic in Reset is ignored, the result is already determined
+		defer callHook(func() { compressor.Reset() })
+		appendChunk = func(data []byte) error {
+			h, err := hookHash(newHash, data)
+			if err != nil {
+				return err
+			}
+			defer zero(h)
+			return callHook(func() { compressor.Write(h) })
+		}
+	}
+	appendLamportSK := appendLamportSKChunks
+	if lowMemory {
+		appendLamportSK = streamLamportSKChunks
+	}
+	//2. lamport_0 = IKM_to_lamport_SK(IKM, salt)
 	//6. for i  in 1, .., 255
 	//       lamport_PK = lamport_PK | SHA256(lamport_0[i])
-	for i := 0; i < 255; i++ {
-		lamportPK = append(lamportPK, SHA256(lamport0[i][:])...)
+	if err := appendLamportSK(ikm, salt, hkdf.Expand, appendChunk); err != nil {
+		return nil, fmt.Errorf("failed lamport_0: %w", err)
 	}
+	//3. not_IKM = flip_bits(IKM)
+	var notIKM [32]byte
+	ikm.flipBitsInto(notIKM[:])
+	defer zero(notIKM[:])
+	//4. lamport_1 = IKM_to_lamport_SK(not_IKM, salt)
 	//7. for i  in 1, .., 255
 	//       lamport_PK = lamport_PK | SHA256(lamport_1[i])
-	for i := 0; i < 255; i++ {
-		lamportPK = append(lamportPK, SHA256(lamport1[i][:])...)
+	if err := appendLamportSK(notIKM[:], salt, hkdf.Expand, appendChunk); err != nil {
+		return nil, fmt.Errorf("failed lamport_1: %w", err)
 	}
 	//8. compressed_lamport_PK = SHA256(lamport_PK)
-	compressedLamportPK := CompressedLamportPK(SHA256(lamportPK))
+	var compressedLamportPK CompressedLamportPK
+	if newHash == nil {
+		compressor.Sum(compressedLamportPK[:0])
+	} else {
+		var h []byte
+		if err := callHook(func() { h = compressor.Sum(nil) }); err != nil {
+			return nil, fmt.Errorf("failed to compress lamport PK: %w", err)
+		}
+		if len(h) != 32 {
+			return nil, fmt.Errorf("failed to compress lamport PK: expected 32 byte hash output, got %d", len(h))
+		}
+		compressedLamportPK = CompressedLamportPK(h)
+	}
 	//9. return compressed_lamport_PK
 	return &compressedLamportPK, nil
 }
 
+// appendLamportSKChunks computes IKM_to_lamport_SK into a pooled buffer, and passes every chunk to appendChunk.
+// The OKM is read from expand, which is hkdf.Expand outside of tests.
+func appendLamportSKChunks(ikm IKM, salt []byte, expand hkdfExpandFunc, appendChunk func(chunk []byte) error) error {
+	buf := getLamportBuf()
+	defer putLamportBuf(buf)
+	if err := ikmToLamportSKWithHash(ikm, salt, sha256.New, expand, buf[:]); err != nil {
+		return fmt.Errorf("failed IKM_to_lamport_SK: %w", err)
+	}
+	for i := 0; i < 255; i++ {
+		if err := appendChunk(lamportChunk(buf, i)); err != nil {
+			return fmt.Errorf("failed to hash element %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// streamLamportSKChunks is appendLamportSKChunks, but passes every chunk to appendChunk
+// as soon as it is read from the HKDF output, so only one chunk is held in memory.
+func streamLamportSKChunks(ikm IKM, salt []byte, expand hkdfExpandFunc, appendChunk func(chunk []byte) error) error {
+	//0. PRK = HKDF-Extract(salt, IKM)
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	//1. OKM = HKDF-Expand(PRK, "" , L)
+	okm := expand(sha256.New, prk, nil)
+	//2. lamport_SK = bytes_split(OKM, K)
+	chunk := make([]byte, 32)
+	defer zero(chunk)
+	for i := 0; i < 255; i++ {
+		if _, err := io.ReadFull(okm, chunk); err != nil {
+			return fmt.Errorf("failed to read OKM data for element %d: %w", i, err)
+		}
+		if err := appendChunk(chunk); err != nil {
+			return fmt.Errorf("failed to hash element %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// parentSKToLamportPKParallel computes the same result as parentSKToLamportPK with SHA256,
+// but computes lamport_1 and its chunk hashes on a separate goroutine, concurrently with lamport_0.
+func parentSKToLamportPKParallel(parentSK *SK, salt []byte) (*CompressedLamportPK, error) {
+	sk32, err := parentIKM(parentSK)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(sk32[:])
+	ikm := IKM(sk32[:])
+	var notIKM [32]byte
+	ikm.flipBitsInto(notIKM[:])
+	defer zero(notIKM[:])
+	// the SHA256 of every lamport_0 chunk, followed by those of lamport_1
+	var chunkHashes [2]lamportChunkHashes
+	defer chunkHashes[0].wipe()
+	defer chunkHashes[1].wipe()
+	var errs [2]error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs[1] = hashLamportChunks(notIKM[:], salt, &chunkHashes[1])
+	}()
+	errs[0] = hashLamportChunks(ikm, salt, &chunkHashes[0])
+	wg.Wait()
+	for j, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed lamport_%d: %w", j, err)
+		}
+	}
+	compressor := sha256.New()
+	defer compressor.Reset()
+	for j := range chunkHashes {
+		for i := range chunkHashes[j] {
+			compressor.Write(chunkHashes[j][i][:])
+		}
+	}
+	var compressedLamportPK CompressedLamportPK
+	compressor.Sum(compressedLamportPK[:0])
+	return &compressedLamportPK, nil
+}
+
+// lamportChunkHashes is the SHA256 of every chunk of a lamport SK: half of the uncompressed lamport_PK.
+// The child SK is derived from the lamport PK, so these are wiped after use too.
+type lamportChunkHashes [255][32]byte
+
+func (h *lamportChunkHashes) wipe() {
+	for i := range h {
+		zero(h[i][:])
+	}
+}
+
+// hashLamportChunks computes IKM_to_lamport_SK, and writes the SHA256 of every lamport SK chunk to out.
+func hashLamportChunks(ikm IKM, salt []byte, out *lamportChunkHashes) error {
+	i := 0
+	return appendLamportSKChunks(ikm, salt, hkdf.Expand, func(chunk []byte) error {
+		out[i] = sha256.Sum256(chunk)
+		i++
+		return nil
+	})
+}
+
+// ErrSKOutOfRange is returned when a secret key is not in the range [0, r).
+var ErrSKOutOfRange = errors.New("secret key out of range")
+
 var r, _ = new(big.Int).SetString("52435875175126190479447740508185965837690552500527637822603658699938581184513", 10)
 
+// rHex is r in hex, as commonly written in curve specifications, to cross-check the decimal constant.
+const rHex = "73eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001"
+
+func init() {
+	if err := checkCurveOrder(); err != nil {
+		panic(err)
+	}
+}
+
+// checkCurveOrder guards against a typo in either representation of r.
+func checkCurveOrder() error {
+	v, ok := new(big.Int).SetString(rHex, 16)
+	if !ok {
+		return errors.New("invalid hex curve order")
+	}
+	if r == nil || r.Cmp(v) != 0 {
+		return fmt.Errorf("decimal curve order %d does not match hex curve order 0x%s", r, rHex)
+	}
+	return nil
+}
+
+// CurveOrder returns a copy of r, the order of the BLS12-381 subgroup: secret keys are in [1, r).
+func CurveOrder() *big.Int {
+	return new(big.Int).Set(r)
+}
+
+// OrderHex returns r, the order of the BLS12-381 subgroup, as 0x-prefixed big-endian hex.
+func OrderHex() string {
+	return "0x" + r.Text(16)
+}
+
+// ScalarToField encodes the secret key as 32 bytes in little-endian order.
+//
+// The ERCs, EIP-2335 keystores, and the [32]byte outputs of this package (e.g. SecretKeyFromHD and I2OSP32)
+// use big-endian. Some curve backends instead represent scalars in little-endian:
+// mixing up the two is a common porting bug, and silently results in a different key.
+//
+// Like SK.Bytes, ScalarToField panics if the key is negative or does not fit in 32 bytes.
+func ScalarToField(sk *SK) (fieldRepr [32]byte) {
+	fieldRepr = sk.Bytes()
+	for i := 0; i < 16; i++ {
+		fieldRepr[i], fieldRepr[31-i] = fieldRepr[31-i], fieldRepr[i]
+	}
+	return fieldRepr
+}
+
+// FieldToScalar is the inverse of ScalarToField: it decodes a little-endian scalar.
+func FieldToScalar(fieldRepr [32]byte) *SK {
+	for i := 0; i < 16; i++ {
+		fieldRepr[i], fieldRepr[31-i] = fieldRepr[31-i], fieldRepr[i]
+	}
+	return (*SK)(osToIP(fieldRepr[:]))
+}
+
+// keygenSalt is the initial salt of HKDF_mod_r.
+const keygenSalt = "BLS-SIG-KEYGEN-SALT-"
+
+// firstKeygenSalt is KeygenSalt(1), the salt of the first (and almost always only) HKDF_mod_r iteration.
+// It must not be modified.
+var firstKeygenSalt = KeygenSalt(1)
+
+// KeygenSalt returns the HKDF_mod_r salt after the given number of salt = H(salt) iterations,
+// starting from "BLS-SIG-KEYGEN-SALT-". The first loop iteration of HKDF_mod_r uses KeygenSalt(1).
+// This helps to debug the salt evolution of other implementations.
+func KeygenSalt(iteration int) []byte {
+	salt := []byte(keygenSalt)
+	for i := 0; i < iteration; i++ {
+		salt = SHA256(salt)
+	}
+	return salt
+}
+
 // HKDFModR implements HKDF_mod_r of ERC-2333.
 //
 // https://eips.ethereum.org/EIPS/eip-2333#hkdf_mod_r
@@ -167,28 +699,56 @@ var r, _ = new(big.Int).SetString("524358751751261904794477405081859658376905525
 //	I2OSP is as defined in RFC3447 (Big endian decoding)
 //	r is the order of the BLS 12-381 curve defined in the v4 draft IETF BLS signature scheme standard
 //	r=52435875175126190479447740508185965837690552500527637822603658699938581184513
+//
+// An IKM shorter than 256 bits is rejected with an error wrapping ErrSeedTooShort.
 func HKDFModR(ikm IKM, keyInfo string) (*SK, error) {
+	return hkdfModR(ikm, keyInfo, nil)
+}
+
+// hkdfModR is HKDF_mod_r instantiated with the hash function h.
+// A nil h is SHA256, as in ERC-2333, for which the first salt is precomputed.
+// L is derived from r, not from h, so it stays 48.
+func hkdfModR(ikm IKM, keyInfo string, h func() hash.Hash) (*SK, error) {
+	if len(ikm) < 32 {
+		return nil, fmt.Errorf("IKM of %d bytes is shorter than 256 bits: %w", len(ikm), ErrSeedTooShort)
+	}
+	newHash := h
+	if newHash == nil {
+		newHash = sha256.New
+	}
 	//1. salt = "BLS-SIG-KEYGEN-SALT-"
-	salt := []byte("BLS-SIG-KEYGEN-SALT-")
+	// The salt is only set by the first H(salt), so that SHA256 can use the precomputed firstKeygenSalt.
+	var salt []byte
+	// The HKDF inputs and the OKM buffer are the same for every iteration.
+	secret := append(append(make([]byte, 0, len(ikm)+1), ikm[:]...), 0)
+	defer zero(secret)
+	// I2OSP(L, 2) = [0, 48]
+	info := append(append(make([]byte, 0, len(keyInfo)+2), keyInfo...), 0, 48)
+	var okm [48]byte
+	defer zero(okm[:])
 	//2. SK = 0
-	sk := big.NewInt(0)
+	sk := new(big.Int)
 	//3. while SK == 0:
-	for sk.IsUint64() && sk.Uint64() == 0 {
+	for sk.Sign() == 0 {
 		//4.     salt = H(salt)
-		salt = SHA256(salt)
+		switch {
+		case salt != nil:
+			salt = hashWith(newHash, salt)
+		case h == nil:
+			salt = firstKeygenSalt
+		default:
+			salt = hashWith(newHash, []byte(keygenSalt))
+		}
 		//5.     PRK = HKDF-Extract(salt, IKM || I2OSP(0, 1))
-		secret := append(append(make([]byte, 0, len(ikm)+1), ikm[:]...), 0)
-		prk := hkdf.Extract(sha256.New, secret, salt)
+		prk := hkdf.Extract(newHash, secret, salt)
 		//6.     OKM = HKDF-Expand(PRK, key_info || I2OSP(L, 2), L)
-		// I2OSP(L, 2) = [0, 48]
-		info := append(append(make([]byte, 0, len(keyInfo)+2), keyInfo...), 0, 48)
-		okmReader := hkdf.Expand(sha256.New, prk, info)
-		var okm [48]byte
+		okmReader := hkdf.Expand(newHash, prk, info)
 		if _, err := io.ReadFull(okmReader, okm[:]); err != nil {
 			return nil, fmt.Errorf("failed reading OKM: %w", err)
 		}
 		//7.     SK = OS2IP(OKM) mod r
-		sk = sk.Mod(osToIP(okm[:]), r)
+		sk.SetBytes(okm[:])
+		sk.Mod(sk, r)
 	}
 	//8. return SK
 	return (*SK)(sk), nil
@@ -208,9 +768,30 @@ func HKDFModR(ikm IKM, keyInfo string) (*SK, error) {
 // Outputs
 //
 //	child_SK, the secret key of the child node, a big endian encoded integer
+//
+// An error wrapping ErrSKOutOfRange is returned if parent_SK is nil or not in the range [1, r).
 func DeriveChildSK(parentSK *SK, index uint32) (*SK, error) {
+	return deriveChildSK(parentSK, index, nil)
+}
+
+// deriveChildSK is DeriveChildSK, with the lamport PK computation configured by opts, which may be nil.
+func deriveChildSK(parentSK *SK, index uint32, opts *DeriveOptions) (*SK, error) {
+	// ERC-2333 assumes the parent is a valid scalar: HKDF_mod_r never produces 0 or values >= r.
+	if !parentSK.InRange() {
+		return nil, fmt.Errorf("invalid parent SK: %w", ErrSKOutOfRange)
+	}
 	//0. compressed_lamport_PK = parent_SK_to_lamport_PK(parent_SK, index)
-	compressedLamportPK, err := ParentSKToLamportPK(parentSK, index)
+	var compressedLamportPK *CompressedLamportPK
+	var err error
+	if opts != nil && opts.LowMemory {
+		salt := i2OSP4(index)
+		compressedLamportPK, err = parentSKToLamportPK(parentSK, salt[:], nil, true)
+	} else if opts != nil && opts.Parallel {
+		salt := i2OSP4(index)
+		compressedLamportPK, err = parentSKToLamportPKParallel(parentSK, salt[:])
+	} else {
+		compressedLamportPK, err = ParentSKToLamportPK(parentSK, index)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed parent_SK_to_lamport_PK: %w", err)
 	}
@@ -219,10 +800,62 @@ func DeriveChildSK(parentSK *SK, index uint32) (*SK, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed HKDF_mod_r: %w", err)
 	}
+	if !sk.InRange() {
+		return nil, fmt.Errorf("derived child SK: %w", ErrSKOutOfRange)
+	}
 	//2. return SK
 	return sk, nil
 }
 
+// DeriveChildSKPath applies DeriveChildSK for each of the indices in order, and returns the last child SK.
+// E.g. with the key at m/12381/3600 as parent, the indices 0, 0, 0 derive the key at m/12381/3600/0/0/0.
+// Without indices, a copy of the parent is returned.
+func DeriveChildSKPath(parentSK *SK, indices ...uint32) (*SK, error) {
+	if !parentSK.InRange() {
+		return nil, fmt.Errorf("invalid parent SK: %w", ErrSKOutOfRange)
+	}
+	sk := (*SK)(new(big.Int).Set((*big.Int)(parentSK)))
+	for i, index := range indices {
+		prevSK := sk
+		var err error
+		sk, err = DeriveChildSK(prevSK, index)
+		// the caller's parent is not touched: the first level is a copy
+		prevSK.Wipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive child %d of the path, index %d: %w", i, index, err)
+		}
+	}
+	return sk, nil
+}
+
+// index64SaltPrefix domain-separates DeriveChildSK64 from DeriveChildSK.
+// HMAC zero-pads its key, so without it I2OSP(0, 8) and I2OSP(0, 4) would be the same HKDF-Extract salt.
+const index64SaltPrefix = "ERC2333-INDEX64-"
+
+// DeriveChildSK64 is a variant of DeriveChildSK with a 64 bit index,
+// using index64SaltPrefix | I2OSP(index, 8) as salt for the lamport keys.
+//
+// This is NOT compatible with ERC-2333: the child keys differ from DeriveChildSK,
+// also for indices that fit in 32 bits. Only use it for custom trees that need a larger index space.
+func DeriveChildSK64(parentSK *SK, index uint64) (*SK, error) {
+	if !parentSK.InRange() {
+		return nil, fmt.Errorf("invalid parent SK: %w", ErrSKOutOfRange)
+	}
+	salt := binary.BigEndian.AppendUint64([]byte(index64SaltPrefix), index)
+	compressedLamportPK, err := parentSKToLamportPK(parentSK, salt, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed parent_SK_to_lamport_PK: %w", err)
+	}
+	sk, err := HKDFModR(compressedLamportPK[:], "")
+	if err != nil {
+		return nil, fmt.Errorf("failed HKDF_mod_r: %w", err)
+	}
+	if !sk.InRange() {
+		return nil, fmt.Errorf("derived child SK: %w", ErrSKOutOfRange)
+	}
+	return sk, nil
+}
+
 // DeriveMasterSK implements derive_master_sk of ERC-2333.
 //
 // https://eips.ethereum.org/EIPS/eip-2333#derive_master_sk
@@ -238,12 +871,132 @@ func DeriveChildSK(parentSK *SK, index uint32) (*SK, error) {
 // Outputs
 //
 //	SK, the secret key of master node within the tree, a big endian encoded integer
+//
+// A seed shorter than 256 bits is rejected with ErrSeedTooShort.
 func DeriveMasterSK(seed Seed) (*SK, error) {
+	return DeriveMasterSKWithKeyInfo(seed, "")
+}
+
+// DeriveMasterSKWithKeyInfo is like DeriveMasterSK, but passes a non-empty key_info to HKDF_mod_r,
+// for key-generation schemes layered on ERC-2333. An empty keyInfo derives the standard master SK.
+func DeriveMasterSKWithKeyInfo(seed Seed, keyInfo string) (*SK, error) {
+	if len(seed) < 32 {
+		return nil, ErrSeedTooShort
+	}
 	//0. SK = HKDF_mod_r(seed)
-	sk, err := HKDFModR(IKM(seed), "")
+	sk, err := HKDFModR(IKM(seed), keyInfo)
 	if err != nil {
 		return nil, fmt.Errorf("failed HKDF_mod_r: %w", err)
 	}
+	if !sk.InRange() {
+		return nil, fmt.Errorf("derived master SK: %w", ErrSKOutOfRange)
+	}
 	//1. return SK
 	return sk, nil
 }
+
+// MaxReaderSeedLength is the maximum seed length accepted by DeriveMasterSKFromReader.
+const MaxReaderSeedLength = 256
+
+// DeriveMasterSKFromReader is like DeriveMasterSK, but reads the seed from src until EOF,
+// so the caller does not need to buffer it. HKDF_mod_r needs the full seed,
+// so it is buffered internally, and wiped after use.
+//
+// At most MaxReaderSeedLength+1 bytes are read from src:
+// a seed longer than MaxReaderSeedLength is rejected, as is a seed shorter than 32 bytes.
+func DeriveMasterSKFromReader(src io.Reader) (*SK, error) {
+	var buf [MaxReaderSeedLength + 1]byte
+	defer zero(buf[:])
+	n, err := io.ReadFull(src, buf[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read seed: %w", err)
+	}
+	if n > MaxReaderSeedLength {
+		return nil, fmt.Errorf("seed is longer than %d bytes", MaxReaderSeedLength)
+	}
+	return DeriveMasterSK(buf[:n])
+}
+
+// DerivationWork returns the number of SHA-256 invocations needed to derive a key
+// at the given depth: the master node, followed by depth child derivations.
+// An HMAC counts as two invocations (inner and outer hash).
+// This counts hash calls, not compression-function blocks,
+// and assumes HKDF_mod_r does not need to retry (probability ~2**-255).
+//
+// Per HKDF_mod_r:
+//
+//	1 salt hash + 1 HMAC for HKDF-Extract + 2 HMACs for 48 bytes of HKDF-Expand = 7
+//
+// Per derive_child_sk:
+//
+//	2 * (1 HMAC for HKDF-Extract + 255 HMACs for HKDF-Expand) = 1024 for the lamport SKs
+//	510 lamport chunk hashes + 1 compression = 511
+//	7 for HKDF_mod_r
+func DerivationWork(depth int) int {
+	const hkdfModR = 1 + 2 + 2*2
+	const ikmToLamportSK = 2 + 255*2
+	const childSK = 2*ikmToLamportSK + 2*255 + 1 + hkdfModR
+	if depth < 0 {
+		return 0
+	}
+	return hkdfModR + depth*childSK
+}
+
+// RandomSK generates a random secret key, uniform in [1, r), by running HKDF_mod_r on 32 bytes of fresh entropy.
+//
+// This is meant for tests and ephemeral keys:
+// there is no seed or path to back up, so the key cannot be recovered.
+func RandomSK() (*SK, error) {
+	var ikm [32]byte
+	if _, err := rand.Read(ikm[:]); err != nil {
+		return nil, fmt.Errorf("failed to read entropy: %w", err)
+	}
+	return HKDFModR(ikm[:], "")
+}
+
+// IsMasterSK checks if sk is the master secret key of the given seed, comparing in constant time.
+func IsMasterSK(seed Seed, sk *SK) (bool, error) {
+	if sk == nil {
+		return false, errors.New("nil secret key")
+	}
+	masterSK, err := DeriveMasterSK(seed)
+	if err != nil {
+		return false, err
+	}
+	defer masterSK.Wipe()
+	// EqualCT wipes its encodings, and never matches a key that is negative or larger than 32 bytes.
+	return masterSK.EqualCT(sk), nil
+}
+
+// SameWallet checks if two seeds have the same master secret key, and thus derive the same key tree.
+// The master keys are compared in constant time, and wiped before returning.
+func SameWallet(seedA, seedB Seed) (bool, error) {
+	masterA, err := DeriveMasterSK(seedA)
+	if err != nil {
+		return false, fmt.Errorf("failed to derive first master SK: %w", err)
+	}
+	defer wipeInt((*big.Int)(masterA))
+	masterB, err := DeriveMasterSK(seedB)
+	if err != nil {
+		return false, fmt.Errorf("failed to derive second master SK: %w", err)
+	}
+	defer wipeInt((*big.Int)(masterB))
+	return masterA.EqualCT(masterB), nil
+}
+
+// zero overwrites b with zeroes.
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// wipeInt overwrites the words of v with zeroes, and sets v to 0.
+// This is best-effort: the big.Int may have been copied before.
+func wipeInt(v *big.Int) {
+	words := v.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	v.SetInt64(0)
+}
@@ -1,11 +1,20 @@
 package bls12_381_hd
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"math/big"
 	"strings"
 	"testing"
+
+	"golang.org/x/crypto/hkdf"
 )
 
 type ERC2333TestCase struct {
@@ -15,42 +24,44 @@ type ERC2333TestCase struct {
 	ChildSK    string
 }
 
-// TestERC2333 tests the key derivation with test-vectors from the ERC itself:
+// erc2333TestCases are the test-vectors from the ERC itself:
 // https://eips.ethereum.org/EIPS/eip-2333#test-cases
+var erc2333TestCases = []ERC2333TestCase{
+	{
+		Seed:       "0xc55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		MasterSK:   "6083874454709270928345386274498605044986640685124978867557563392430687146096",
+		ChildIndex: 0,
+		ChildSK:    "20397789859736650942317412262472558107875392172444076792671091975210932703118",
+	},
+	{
+		Seed:       "0x3141592653589793238462643383279502884197169399375105820974944592",
+		MasterSK:   "29757020647961307431480504535336562678282505419141012933316116377660817309383",
+		ChildIndex: 3141592653,
+		ChildSK:    "25457201688850691947727629385191704516744796114925897962676248250929345014287",
+	},
+	{
+		Seed:       "0x0099FF991111002299DD7744EE3355BBDD8844115566CC55663355668888CC00",
+		MasterSK:   "27580842291869792442942448775674722299803720648445448686099262467207037398656",
+		ChildIndex: 4294967295,
+		ChildSK:    "29358610794459428860402234341874281240803786294062035874021252734817515685787",
+	},
+	{
+		Seed:       "0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3",
+		MasterSK:   "19022158461524446591288038168518313374041767046816487870552872741050760015818",
+		ChildIndex: 42,
+		ChildSK:    "31372231650479070279774297061823572166496564838472787488249775572789064611981",
+	},
+	{
+		Seed:       "0xc55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		MasterSK:   "6083874454709270928345386274498605044986640685124978867557563392430687146096",
+		ChildIndex: 0,
+		ChildSK:    "20397789859736650942317412262472558107875392172444076792671091975210932703118",
+	},
+}
+
+// TestERC2333 tests the key derivation with the ERC test-vectors.
 func TestERC2333(t *testing.T) {
-	testCases := []ERC2333TestCase{
-		{
-			Seed:       "0xc55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
-			MasterSK:   "6083874454709270928345386274498605044986640685124978867557563392430687146096",
-			ChildIndex: 0,
-			ChildSK:    "20397789859736650942317412262472558107875392172444076792671091975210932703118",
-		},
-		{
-			Seed:       "0x3141592653589793238462643383279502884197169399375105820974944592",
-			MasterSK:   "29757020647961307431480504535336562678282505419141012933316116377660817309383",
-			ChildIndex: 3141592653,
-			ChildSK:    "25457201688850691947727629385191704516744796114925897962676248250929345014287",
-		},
-		{
-			Seed:       "0x0099FF991111002299DD7744EE3355BBDD8844115566CC55663355668888CC00",
-			MasterSK:   "27580842291869792442942448775674722299803720648445448686099262467207037398656",
-			ChildIndex: 4294967295,
-			ChildSK:    "29358610794459428860402234341874281240803786294062035874021252734817515685787",
-		},
-		{
-			Seed:       "0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3",
-			MasterSK:   "19022158461524446591288038168518313374041767046816487870552872741050760015818",
-			ChildIndex: 42,
-			ChildSK:    "31372231650479070279774297061823572166496564838472787488249775572789064611981",
-		},
-		{
-			Seed:       "0xc55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
-			MasterSK:   "6083874454709270928345386274498605044986640685124978867557563392430687146096",
-			ChildIndex: 0,
-			ChildSK:    "20397789859736650942317412262472558107875392172444076792671091975210932703118",
-		},
-	}
-	for i, tc := range testCases {
+	for i, tc := range erc2333TestCases {
 		t.Run(fmt.Sprintf("case_%d", i), func(t *testing.T) {
 			if strings.HasPrefix(tc.Seed, "0x") {
 				tc.Seed = tc.Seed[2:]
@@ -108,7 +119,709 @@ func TestERC2333(t *testing.T) {
 						t.Fatalf("got %d but expected %d", (*big.Int)(gotChildSK), childSK)
 					}
 				})
+				t.Run("childSK_parallel", func(t *testing.T) {
+					gotChildSK, err := deriveChildSK((*SK)(masterSK), tc.ChildIndex, &DeriveOptions{Parallel: true})
+					if err != nil {
+						t.Fatalf("failed to derive child SK: %v", err)
+					}
+					if childSK.Cmp((*big.Int)(gotChildSK)) != 0 {
+						t.Fatalf("got %d but expected %d", (*big.Int)(gotChildSK), childSK)
+					}
+				})
 			})
 		})
 	}
 }
+
+func TestCtEqual32(t *testing.T) {
+	var a, b [32]byte
+	for i := range a {
+		a[i] = byte(i)
+	}
+	b = a
+	if !ctEqual32(&a, &b) {
+		t.Fatal("expected equal values to compare equal")
+	}
+	// every position must be considered, not just a prefix or suffix
+	for i := 0; i < 32; i++ {
+		c := a
+		c[i] ^= 0x80
+		if ctEqual32(&a, &c) {
+			t.Fatalf("expected difference at byte %d to be detected", i)
+		}
+	}
+}
+
+func TestDerivationWork(t *testing.T) {
+	// master only: 1 salt hash, HKDF-Extract HMAC (2), two HKDF-Expand HMACs (4)
+	if got := DerivationWork(0); got != 7 {
+		t.Fatalf("depth 0: got %d, expected 7", got)
+	}
+	// each child: 2*(2+510) lamport SK + 510 chunk hashes + 1 compression + 7 HKDF_mod_r = 1542
+	if got := DerivationWork(1); got != 7+1542 {
+		t.Fatalf("depth 1: got %d, expected %d", got, 7+1542)
+	}
+	// m/12381/3600/0/0/0
+	if got := DerivationWork(5); got != 7+5*1542 {
+		t.Fatalf("depth 5: got %d, expected %d", got, 7+5*1542)
+	}
+	if got := DerivationWork(-1); got != 0 {
+		t.Fatalf("negative depth: got %d, expected 0", got)
+	}
+}
+
+type countingHash struct {
+	hash.Hash
+	count *int
+}
+
+func (h countingHash) Sum(b []byte) []byte {
+	*h.count += 1
+	return h.Hash.Sum(b)
+}
+
+func TestParentSKToLamportPKWithHash(t *testing.T) {
+	parentSK, err := DeriveMasterSK(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ParentSKToLamportPK(parentSK, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	got, err := ParentSKToLamportPKWithHash(parentSK, 42, func() hash.Hash {
+		return countingHash{Hash: sha256.New(), count: &count}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *expected {
+		t.Fatalf("got %x but expected %x", got[:], expected[:])
+	}
+	// 510 lamport chunks and 1 compression
+	if count != 511 {
+		t.Fatalf("expected injected hash to be used 511 times, got %d", count)
+	}
+	if _, err := ParentSKToLamportPKWithHash(parentSK, 42, sha512.New); err == nil {
+		t.Fatal("expected hash with wrong output size to be rejected")
+	}
+}
+
+func TestIsMasterSK(t *testing.T) {
+	seed := make([]byte, 32)
+	masterSK, err := DeriveMasterSK(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	childSK, err := DeriveChildSK(masterSK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := IsMasterSK(seed, masterSK); err != nil || !ok {
+		t.Fatalf("expected master SK to match, got %v, %v", ok, err)
+	}
+	if ok, err := IsMasterSK(seed, childSK); err != nil || ok {
+		t.Fatalf("expected child SK not to match, got %v, %v", ok, err)
+	}
+}
+
+func TestRandomSK(t *testing.T) {
+	a, err := RandomSK()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	b, err := RandomSK()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	for _, sk := range []*SK{a, b} {
+		v := (*big.Int)(sk)
+		if v.Sign() <= 0 || v.Cmp(r) >= 0 {
+			t.Fatalf("key %d out of range", v)
+		}
+	}
+	if (*big.Int)(a).Cmp((*big.Int)(b)) == 0 {
+		t.Fatal("expected two random keys to differ")
+	}
+}
+
+func TestDeriveChildSKOutOfRange(t *testing.T) {
+	testCases := []struct {
+		name  string
+		sk    *big.Int
+		valid bool
+	}{
+		{"nil", nil, false},
+		{"negative", big.NewInt(-1), false},
+		{"zero", big.NewInt(0), false},
+		{"one", big.NewInt(1), true},
+		{"r-1", new(big.Int).Sub(r, big.NewInt(1)), true},
+		{"r", new(big.Int).Set(r), false},
+		{"r+1", new(big.Int).Add(r, big.NewInt(1)), false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DeriveChildSK((*SK)(tc.sk), 0)
+			if tc.valid && err != nil {
+				t.Fatalf("expected valid parent, got %v", err)
+			}
+			if !tc.valid && !errors.Is(err, ErrSKOutOfRange) {
+				t.Fatalf("expected out of range error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDeriveChildSK64(t *testing.T) {
+	parentSK, err := DeriveMasterSK(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// index 0 matters most: HMAC zero-pads the salt, so plain zero salts of any length collide
+	for _, index := range []uint32{0, 1, 42} {
+		a, err := DeriveChildSK(parentSK, index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := DeriveChildSK64(parentSK, uint64(index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (*big.Int)(a).Cmp((*big.Int)(b)) == 0 {
+			t.Fatalf("index %d: expected 64 bit variant to derive a different key", index)
+		}
+	}
+	a, err := DeriveChildSK64(parentSK, 1<<40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := DeriveChildSK64(parentSK, 1<<40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*big.Int)(a).Cmp((*big.Int)(b)) != 0 {
+		t.Fatal("expected derivation to be deterministic")
+	}
+	for _, parent := range []*SK{nil, (*SK)(big.NewInt(0)), (*SK)(new(big.Int).Set(r))} {
+		if _, err := DeriveChildSK64(parent, 1); !errors.Is(err, ErrSKOutOfRange) {
+			t.Fatalf("expected parent %v to be rejected, got %v", parent, err)
+		}
+	}
+}
+
+type panickingHash struct {
+	hash.Hash
+}
+
+func (h panickingHash) Sum(b []byte) []byte {
+	panic("broken hash")
+}
+
+func TestHookPanic(t *testing.T) {
+	parentSK, err := DeriveMasterSK(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParentSKToLamportPKWithHash(parentSK, 0, func() hash.Hash {
+		return panickingHash{Hash: sha256.New()}
+	})
+	if !errors.Is(err, ErrHookPanic) {
+		t.Fatalf("expected hook panic error, got %v", err)
+	}
+	_, err = ParentSKToLamportPKWithHash(parentSK, 0, func() hash.Hash {
+		panic("broken constructor")
+	})
+	if !errors.Is(err, ErrHookPanic) {
+		t.Fatalf("expected hook panic error, got %v", err)
+	}
+}
+
+func TestKeygenSalt(t *testing.T) {
+	if got := string(KeygenSalt(0)); got != "BLS-SIG-KEYGEN-SALT-" {
+		t.Fatalf("unexpected initial salt %q", got)
+	}
+	first := sha256.Sum256([]byte("BLS-SIG-KEYGEN-SALT-"))
+	if got := KeygenSalt(1); !bytes.Equal(got, first[:]) {
+		t.Fatalf("got %x but expected %x", got, first)
+	}
+	second := sha256.Sum256(first[:])
+	if got := KeygenSalt(2); !bytes.Equal(got, second[:]) {
+		t.Fatalf("got %x but expected %x", got, second)
+	}
+	if _, err := HKDFModR(make([]byte, 32), ""); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(firstKeygenSalt, first[:]) {
+		t.Fatalf("precomputed salt %x was modified, expected %x", firstKeygenSalt, first)
+	}
+}
+
+func TestCurveOrder(t *testing.T) {
+	if err := checkCurveOrder(); err != nil {
+		t.Fatal(err)
+	}
+	dec, _ := new(big.Int).SetString("52435875175126190479447740508185965837690552500527637822603658699938581184513", 10)
+	hexOrder, _ := new(big.Int).SetString(rHex, 16)
+	if dec.Cmp(hexOrder) != 0 {
+		t.Fatal("decimal and hex curve order differ")
+	}
+	if CurveOrder().String() != "52435875175126190479447740508185965837690552500527637822603658699938581184513" {
+		t.Fatal("unexpected curve order")
+	}
+	if got := OrderHex(); got != "0x73eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001" {
+		t.Fatalf("unexpected curve order hex %s", got)
+	}
+	// mutating the returned value must not affect the package
+	CurveOrder().SetInt64(0)
+	if r.Sign() == 0 {
+		t.Fatal("curve order was mutated")
+	}
+}
+
+func TestScalarToField(t *testing.T) {
+	sk := (*SK)(big.NewInt(0x0102))
+	le := ScalarToField(sk)
+	// little-endian: least significant byte first
+	if le[0] != 0x02 || le[1] != 0x01 || le[31] != 0 {
+		t.Fatalf("unexpected little-endian encoding %x", le)
+	}
+	be := I2OSP32((*big.Int)(sk))
+	if be[31] != 0x02 || be[30] != 0x01 || be[0] != 0 {
+		t.Fatalf("unexpected big-endian encoding %x", be)
+	}
+	masterSK, err := DeriveMasterSK(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FieldToScalar(ScalarToField(masterSK)); (*big.Int)(got).Cmp((*big.Int)(masterSK)) != 0 {
+		t.Fatal("round-trip changed the scalar")
+	}
+}
+
+func TestSameWallet(t *testing.T) {
+	a := make([]byte, 32)
+	b := make([]byte, 32)
+	if same, err := SameWallet(a, b); err != nil || !same {
+		t.Fatalf("expected identical seeds to match, got %v, %v", same, err)
+	}
+	b[0] = 1
+	if same, err := SameWallet(a, b); err != nil || same {
+		t.Fatalf("expected different seeds not to match, got %v, %v", same, err)
+	}
+}
+
+func TestWipeInt(t *testing.T) {
+	v, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	words := v.Bits()
+	wipeInt(v)
+	if v.Sign() != 0 {
+		t.Fatal("expected value to be zero")
+	}
+	for i, w := range words {
+		if w != 0 {
+			t.Fatalf("word %d was not wiped", i)
+		}
+	}
+}
+
+func TestWipe(t *testing.T) {
+	seed := Seed{1, 2, 3, 4}
+	sk, err := DeriveMasterSK(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := (*big.Int)(sk).Bits()
+	seed.Wipe()
+	sk.Wipe()
+	for i, b := range seed {
+		if b != 0 {
+			t.Fatalf("seed byte %d was not wiped", i)
+		}
+	}
+	if (*big.Int)(sk).Sign() != 0 {
+		t.Fatal("expected key to be zero")
+	}
+	for i, w := range words {
+		if w != 0 {
+			t.Fatalf("key word %d was not wiped", i)
+		}
+	}
+}
+
+func TestLamportSKReadFailure(t *testing.T) {
+	ikm := make([]byte, 32)
+	salt := []byte{0, 0, 0, 0}
+	shortExpand := func(h func() hash.Hash, prk, info []byte) io.Reader {
+		return io.LimitReader(hkdf.Expand(h, prk, info), 100*32)
+	}
+	noop := func([]byte) error { return nil }
+	for name, appendLamportSK := range map[string]func(IKM, []byte, hkdfExpandFunc, func([]byte) error) error{
+		"pooled":    appendLamportSKChunks,
+		"streaming": streamLamportSKChunks,
+	} {
+		if err := appendLamportSK(ikm, salt, shortExpand, noop); !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			t.Fatalf("%s: expected short read to fail, got %v", name, err)
+		}
+		if err := appendLamportSK(ikm, salt, hkdf.Expand, noop); err != nil {
+			t.Fatalf("%s: expected full read to succeed, got %v", name, err)
+		}
+	}
+}
+
+func TestI2OSP32Checked(t *testing.T) {
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	out, err := I2OSP32Checked(max)
+	if err != nil {
+		t.Fatalf("expected 2**256-1 to fit: %v", err)
+	}
+	if out != I2OSP32(max) {
+		t.Fatal("checked encoding differs")
+	}
+	if _, err := I2OSP32Checked(new(big.Int).Lsh(big.NewInt(1), 256)); err == nil {
+		t.Fatal("expected 2**256 to be rejected")
+	}
+	if _, err := I2OSP32Checked(big.NewInt(-1)); err == nil {
+		t.Fatal("expected negative integer to be rejected")
+	}
+}
+
+func TestLamportSKWipe(t *testing.T) {
+	lamportSK, err := IKMToLamportSK(make([]byte, 32), Salt{0, 0, 0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lamportSK.Wipe()
+	if *lamportSK != (LamportSK{}) {
+		t.Fatal("expected all lamport SK chunks to be zeroed")
+	}
+}
+
+func TestLamportSKPoolZeroed(t *testing.T) {
+	ResetPools()
+	parentSK, err := DeriveMasterSK(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParentSKToLamportPK(parentSK, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if buf := getLamportBuf(); *buf != (lamportBuf{}) {
+			t.Fatal("expected pooled lamport SK buffer to be zeroed")
+		}
+	}
+}
+
+func BenchmarkParentSKToLamportPK(b *testing.B) {
+	parentSK, err := DeriveMasterSK(make([]byte, 32))
+	if err != nil {
+		b.Fatal(err)
+	}
+	ResetPools()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParentSKToLamportPK(parentSK, uint32(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestHKDFModRWithHash(t *testing.T) {
+	ikm := make([]byte, 32)
+	expected, err := HKDFModR(ikm, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := hkdfModR(ikm, "", sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*big.Int)(got).Cmp((*big.Int)(expected)) != 0 {
+		t.Fatal("expected explicit SHA256 to match HKDF_mod_r")
+	}
+	other, err := hkdfModR(ikm, "", sha512.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := (*big.Int)(other); v.Cmp((*big.Int)(expected)) == 0 || v.Sign() <= 0 || v.Cmp(r) >= 0 {
+		t.Fatalf("unexpected SHA512 result %d", v)
+	}
+}
+
+func TestIKMToLamportSKWithHash(t *testing.T) {
+	ikm := make([]byte, 32)
+	salt := Salt{0, 0, 0, 7}
+	expected, err := IKMToLamportSK(ikm, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got lamportBuf
+	if err := ikmToLamportSKWithHash(ikm, salt[:], sha256.New, hkdf.Expand, got[:]); err != nil {
+		t.Fatal(err)
+	}
+	for i := range expected {
+		if !bytes.Equal(lamportChunk(&got, i), expected[i][:]) {
+			t.Fatalf("expected explicit SHA256 to match IKM_to_lamport_SK at chunk %d", i)
+		}
+	}
+	if err := ikmToLamportSKWithHash(ikm, salt[:], sha512.New, hkdf.Expand, make([]byte, 255*64)); err != nil {
+		t.Fatalf("expected 255*64 byte buffer with SHA512: %v", err)
+	}
+	if err := ikmToLamportSKWithHash(ikm, salt[:], sha512.New, hkdf.Expand, got[:]); err == nil {
+		t.Fatal("expected 255*32 byte buffer to be rejected with SHA512")
+	}
+}
+
+func TestSKEncoding(t *testing.T) {
+	sk := (*SK)(big.NewInt(0x1234))
+	b := sk.Bytes()
+	if b[30] != 0x12 || b[31] != 0x34 || !bytes.Equal(b[:30], make([]byte, 30)) {
+		t.Fatalf("unexpected bytes %x", b)
+	}
+	if got := sk.Hex(); got != "0000000000000000000000000000000000000000000000000000000000001234" {
+		t.Fatalf("unexpected hex %s", got)
+	}
+	if got := sk.String(); got != "4660" {
+		t.Fatalf("unexpected string %s", got)
+	}
+}
+
+func TestSKEncodingOutOfRange(t *testing.T) {
+	huge := (*SK)(new(big.Int).Lsh(big.NewInt(1), 256))
+	for _, sk := range []*SK{nil, (*SK)(big.NewInt(-1)), (*SK)(new(big.Int).Set(r)), huge} {
+		if _, err := sk.MarshalText(); !errors.Is(err, ErrSKOutOfRange) {
+			t.Fatalf("key %v: expected out of range error, got %v", sk, err)
+		}
+	}
+	for _, sk := range []*SK{(*SK)(big.NewInt(-1)), huge} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("key %v: expected Bytes to panic", sk)
+				}
+			}()
+			sk.Bytes()
+		}()
+	}
+}
+
+func TestSKJSON(t *testing.T) {
+	type wrapper struct {
+		Key *SK `json:"key"`
+	}
+	sk, err := DeriveMasterSK(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(wrapper{Key: sk})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"key":"0x` + sk.Hex() + `"}`; string(data) != expected {
+		t.Fatalf("got %s, expected %s", data, expected)
+	}
+	var got wrapper
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if (*big.Int)(got.Key).Cmp((*big.Int)(sk)) != 0 {
+		t.Fatal("decoded key differs")
+	}
+	rHexJSON := `{"key":"0x` + rHex + `"}`
+	for _, invalid := range []string{`{"key":"` + sk.Hex() + `"}`, `{"key":"0x1234"}`, `{"key":123}`, rHexJSON} {
+		if err := json.Unmarshal([]byte(invalid), &got); err == nil {
+			t.Fatalf("expected %s to be rejected", invalid)
+		}
+	}
+	if err := json.Unmarshal([]byte(rHexJSON), &got); !errors.Is(err, ErrSKOutOfRange) {
+		t.Fatalf("expected out of range error, got %v", err)
+	}
+	if _, err := json.Marshal((*SK)(new(big.Int).Set(r))); !errors.Is(err, ErrSKOutOfRange) {
+		t.Fatalf("expected out of range error, got %v", err)
+	}
+}
+
+func TestShortSeedRejected(t *testing.T) {
+	short := make([]byte, 31)
+	if _, err := DeriveMasterSK(short); !errors.Is(err, ErrSeedTooShort) {
+		t.Fatalf("DeriveMasterSK: expected short seed error, got %v", err)
+	}
+	if _, err := DeriveMasterSKWithKeyInfo(short, "info"); !errors.Is(err, ErrSeedTooShort) {
+		t.Fatalf("DeriveMasterSKWithKeyInfo: expected short seed error, got %v", err)
+	}
+	if _, err := HKDFModR(short, ""); !errors.Is(err, ErrSeedTooShort) {
+		t.Fatalf("HKDFModR: expected short IKM error, got %v", err)
+	}
+	if _, err := SecretKeyFromHD(short, "m/0"); !errors.Is(err, ErrSeedTooShort) {
+		t.Fatalf("SecretKeyFromHD: expected short seed error, got %v", err)
+	}
+}
+
+func TestDeriveMasterSKFromReader(t *testing.T) {
+	seed := make([]byte, 64)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	expected, err := DeriveMasterSK(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a reader that returns the seed in small pieces
+	got, err := DeriveMasterSKFromReader(io.MultiReader(bytes.NewReader(seed[:10]), bytes.NewReader(seed[10:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*big.Int)(got).Cmp((*big.Int)(expected)) != 0 {
+		t.Fatal("master SK differs")
+	}
+	if _, err := DeriveMasterSKFromReader(bytes.NewReader(seed[:31])); !errors.Is(err, ErrSeedTooShort) {
+		t.Fatalf("expected short seed error, got %v", err)
+	}
+	if _, err := DeriveMasterSKFromReader(bytes.NewReader(make([]byte, MaxReaderSeedLength+1))); err == nil {
+		t.Fatal("expected long seed to be rejected")
+	}
+	if _, err := DeriveMasterSKFromReader(bytes.NewReader(make([]byte, MaxReaderSeedLength))); err != nil {
+		t.Fatalf("expected max length seed to be accepted: %v", err)
+	}
+}
+
+func TestSKEqualCT(t *testing.T) {
+	a := (*SK)(big.NewInt(42))
+	b := (*SK)(big.NewInt(42))
+	c := (*SK)(big.NewInt(43))
+	if !a.EqualCT(b) {
+		t.Fatal("expected equal keys to match")
+	}
+	if a.EqualCT(c) {
+		t.Fatal("expected different keys not to match")
+	}
+	if a.EqualCT(nil) || (*SK)(nil).EqualCT(a) {
+		t.Fatal("expected nil keys not to match")
+	}
+	huge := (*SK)(new(big.Int).Lsh(big.NewInt(1), 300))
+	if huge.EqualCT(huge) {
+		t.Fatal("expected keys larger than 32 bytes not to match")
+	}
+}
+
+func TestParentSKToLamportPKInvalidIKM(t *testing.T) {
+	huge := new(big.Int).Lsh(big.NewInt(1), 256)
+	for _, v := range []*big.Int{nil, big.NewInt(-1), huge} {
+		if _, err := ParentSKToLamportPK((*SK)(v), 0); err == nil {
+			t.Fatalf("expected parent SK %v to be rejected", v)
+		}
+		if _, err := parentSKToLamportPK((*SK)(v), []byte{0, 0, 0, 0}, nil, true); err == nil {
+			t.Fatalf("expected parent SK %v to be rejected in low memory mode", v)
+		}
+		if _, err := parentSKToLamportPKParallel((*SK)(v), []byte{0, 0, 0, 0}); err == nil {
+			t.Fatalf("expected parent SK %v to be rejected in parallel mode", v)
+		}
+	}
+}
+
+func TestFlipBitsInto(t *testing.T) {
+	ikm := IKM{0x00, 0xff, 0x0f}
+	out := make(IKM, 3)
+	ikm.flipBitsInto(out)
+	if !bytes.Equal(out, []byte{0xff, 0x00, 0xf0}) {
+		t.Fatalf("unexpected flipped bits %x", out)
+	}
+	if allocs := testing.AllocsPerRun(10, func() { ikm.flipBitsInto(out) }); allocs != 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestSKInRange(t *testing.T) {
+	testCases := []struct {
+		sk       *big.Int
+		expected bool
+	}{
+		{nil, false},
+		{big.NewInt(-1), false},
+		{big.NewInt(0), false},
+		{big.NewInt(1), true},
+		{new(big.Int).Sub(r, big.NewInt(1)), true},
+		{new(big.Int).Set(r), false},
+	}
+	for _, tc := range testCases {
+		if got := (*SK)(tc.sk).InRange(); got != tc.expected {
+			t.Fatalf("%v: got %v, expected %v", tc.sk, got, tc.expected)
+		}
+	}
+}
+
+func TestCompressLamportPK(t *testing.T) {
+	parentSK, err := DeriveMasterSK(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ParentSKToLamportPK(parentSK, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ikm := parentSK.Bytes()
+	got, err := CompressLamportPK(ikm[:], Salt{0, 0, 0, 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *expected {
+		t.Fatalf("got %x but expected %x", got[:], expected[:])
+	}
+	if _, err := CompressLamportPK(ikm[:31], Salt{}); err == nil {
+		t.Fatal("expected short IKM to be rejected")
+	}
+}
+
+func TestSaltFromIndex(t *testing.T) {
+	salt := SaltFromIndex(0x01020304)
+	if salt != (Salt{1, 2, 3, 4}) {
+		t.Fatalf("expected big-endian salt, got %x", salt[:])
+	}
+	for _, index := range []uint32{0, 42, 1<<32 - 1} {
+		if got := SaltFromIndex(index).Index(); got != index {
+			t.Fatalf("got %d, expected %d", got, index)
+		}
+	}
+}
+
+func TestDeriveChildSKPath(t *testing.T) {
+	seed := make([]byte, 32)
+	parentSK, err := SecretKeyFromHDSK(seed, "m/12381/3600")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := DeriveChildSKPath(parentSK, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := SecretKeyFromHD(seed, "m/12381/3600/0/0/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk.Bytes() != *expected {
+		t.Fatal("key differs")
+	}
+	same, err := DeriveChildSKPath(parentSK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same == parentSK || !same.EqualCT(parentSK) {
+		t.Fatal("expected a copy of the parent without indices")
+	}
+	if _, err := DeriveChildSKPath((*SK)(big.NewInt(0)), 1); !errors.Is(err, ErrSKOutOfRange) {
+		t.Fatalf("expected out of range error, got %v", err)
+	}
+}
+
+func BenchmarkHKDFModR(b *testing.B) {
+	ikm := make([]byte, 32)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := HKDFModR(ikm, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
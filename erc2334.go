@@ -1,57 +1,283 @@
 package bls12_381_hd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
-	"strconv"
-	"strings"
+	"time"
 )
 
+// ErrDeadlineExceeded is returned when derivation is aborted because the context deadline passed.
+var ErrDeadlineExceeded = errors.New("key derivation deadline exceeded")
+
+// ErrSeedTooShort is returned for seeds shorter than the 32 bytes required by ERC-2333.
+var ErrSeedTooShort = errors.New("seed is too short")
+
+// ErrNoChildInPath is returned when DeriveOptions.RequireChild is set and the path has no child node.
+var ErrNoChildInPath = errors.New("path has no child node")
+
 // SecretKeyFromHD derives a BLS12-381 secret key from a seed and an
 // hierarchical derivation path (HD path) as specified in ERC-2334.
 //
 // See BIP-39 to turn a mnemonic seed-phrase into seed bytes.
 func SecretKeyFromHD(seed []byte, path string) (*[32]byte, error) {
-	if path == "" {
-		return nil, errors.New("path must not be empty")
+	sk, err := SecretKeyFromHDSK(seed, path)
+	if err != nil {
+		return nil, err
+	}
+	defer sk.Wipe()
+	out, err := I2OSP32Checked((*big.Int)(sk))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode derived key: %w", err)
+	}
+	return &out, nil
+}
+
+// SecretKeyFromHDSK is like SecretKeyFromHD, but returns the key as SK,
+// e.g. to continue with DeriveChildSK without converting it back from bytes.
+func SecretKeyFromHDSK(seed []byte, path string) (*SK, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
 	}
 	if len(seed) < 32 {
-		return nil, errors.New("seed is too short")
-	}
-	segments := strings.Split(path, "/")
-	var outSK *SK
-	for i, seg := range segments {
-		if seg == "" {
-			return nil, fmt.Errorf("path segment %d is empty", i)
-		}
-		if seg == "m" {
-			if i != 0 {
-				return nil, fmt.Errorf("unexpected master node in segment %d", i)
-			}
-			sk, err := DeriveMasterSK(seed)
-			if err != nil {
-				return nil, fmt.Errorf("failed to derive secret key from master node: %w", err)
-			}
-			outSK = sk
-		} else {
-			if i == 0 {
-				return nil, errors.New("missing master node at segment 0")
-			}
-			index, err := strconv.ParseUint(seg, 10, 32)
-			if err != nil {
-				return nil, fmt.Errorf("invalid child node at segment %d, value %q: %w", i, seg, err)
-			}
-			sk, err := DeriveChildSK(outSK, uint32(index))
-			if err != nil {
-				return nil, fmt.Errorf("failed to derive secret key from child node at segment %d, index %d: %w", i, index, err)
-			}
-			outSK = sk
-		}
-	}
-	if outSK == nil {
-		return nil, errors.New("failed to derive key")
-	}
-	out := I2OSP32((*big.Int)(outSK))
+		return nil, ErrSeedTooShort
+	}
+	return deriveSK(context.Background(), seed, p, nil)
+}
+
+// SecretKeyFromHDContext is like SecretKeyFromHD, but checks ctx before every path segment,
+// to bound the CPU time spent on deep paths from untrusted input.
+// If the context deadline passed, the returned error wraps ErrDeadlineExceeded.
+func SecretKeyFromHDContext(ctx context.Context, seed []byte, path string) (*[32]byte, error) {
+	return SecretKeyFromHDWithOptions(ctx, seed, path, nil)
+}
+
+// DeriveOptions enables optional checks on top of the ERC-2334 derivation.
+// The zero value (or nil) derives exactly like SecretKeyFromHD.
+type DeriveOptions struct {
+	// RequireChild rejects a path that is just the master node "m",
+	// for callers that always expect a child key.
+	RequireChild bool
+	// LowMemory hashes the lamport chunks as they are read from HKDF, instead of first reading
+	// each lamport SK into a pooled 8KB buffer. The derived keys are the same.
+	LowMemory bool
+	// Parallel computes the two lamport SKs of every child derivation on separate goroutines.
+	// This reduces latency on multi-core machines, at the cost of a goroutine per child.
+	// The derived keys are the same. LowMemory takes precedence if both are set.
+	Parallel bool
+	// AllowList restricts derivation to the listed paths, rejecting others with ErrPathNotAllowed
+	// before any derivation work. An invalid pattern is an error, see PathAllowList.Validate.
+	// Nil allows all paths.
+	AllowList PathAllowList
+	// KeyInfo is passed as key_info to HKDF_mod_r when deriving the master SK.
+	// It is empty in standard ERC-2334 derivation.
+	KeyInfo string
+}
+
+// SecretKeyFromHDWithOptions is like SecretKeyFromHDContext, with additional options.
+// The options may be nil.
+func SecretKeyFromHDWithOptions(ctx context.Context, seed []byte, path string, opts *DeriveOptions) (*[32]byte, error) {
+	if opts == nil {
+		opts = new(DeriveOptions)
+	}
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if opts.RequireChild && len(p) == 0 {
+		return nil, ErrNoChildInPath
+	}
+	if opts.AllowList != nil {
+		if err := opts.AllowList.Validate(); err != nil {
+			return nil, err
+		}
+		if !opts.AllowList.Allows(path) {
+			return nil, fmt.Errorf("%w: %q", ErrPathNotAllowed, path)
+		}
+	}
+	return secretKeyFromPath(ctx, seed, p, opts)
+}
+
+// SecretKeyFromHDWithKeyInfo is like SecretKeyFromHD, but derives the master SK with a non-empty key_info,
+// see DeriveMasterSKWithKeyInfo. The child derivations are not affected.
+func SecretKeyFromHDWithKeyInfo(seed []byte, path, keyInfo string) (*[32]byte, error) {
+	return SecretKeyFromHDWithOptions(context.Background(), seed, path, &DeriveOptions{KeyInfo: keyInfo})
+}
+
+// SecretKeyFromPath is like SecretKeyFromHD, but takes an already parsed path.
+func SecretKeyFromPath(seed []byte, p Path) (*[32]byte, error) {
+	return secretKeyFromPath(context.Background(), seed, p, nil)
+}
+
+func secretKeyFromPath(ctx context.Context, seed []byte, p Path, opts *DeriveOptions) (*[32]byte, error) {
+	if len(seed) < 32 {
+		return nil, ErrSeedTooShort
+	}
+	sk, err := deriveSK(ctx, seed, p, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer sk.Wipe()
+	out, err := I2OSP32Checked((*big.Int)(sk))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode derived key: %w", err)
+	}
 	return &out, nil
 }
+
+// deriveSK derives the master node of the seed, followed by the child nodes of the path.
+// The options may be nil.
+func deriveSK(ctx context.Context, seed []byte, p Path, opts *DeriveOptions) (*SK, error) {
+	if err := checkContext(ctx, 0); err != nil {
+		return nil, err
+	}
+	keyInfo := ""
+	if opts != nil {
+		keyInfo = opts.KeyInfo
+	}
+	sk, err := DeriveMasterSKWithKeyInfo(seed, keyInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive secret key from master node: %w", err)
+	}
+	for i, index := range p {
+		segment := i + 1
+		if err := checkContext(ctx, segment); err != nil {
+			sk.Wipe()
+			return nil, err
+		}
+		parentSK := sk
+		sk, err = deriveChildSK(parentSK, index, opts)
+		parentSK.Wipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive secret key from child node at segment %d, index %d: %w", segment, index, err)
+		}
+	}
+	return sk, nil
+}
+
+// DeriveChain derives the keys of every node along an ERC-2334 path:
+// the master SK at index 0, followed by each child SK, ending with the key of the path itself.
+func DeriveChain(seed []byte, path string) ([]*[32]byte, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) < 32 {
+		return nil, ErrSeedTooShort
+	}
+	sk, err := DeriveMasterSK(seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive secret key from master node: %w", err)
+	}
+	out := make([]*[32]byte, 0, len(p)+1)
+	for segment := 0; ; segment++ {
+		key, err := I2OSP32Checked((*big.Int)(sk))
+		if err != nil {
+			sk.Wipe()
+			return nil, fmt.Errorf("failed to encode derived key at segment %d: %w", segment, err)
+		}
+		out = append(out, &key)
+		if segment == len(p) {
+			sk.Wipe()
+			return out, nil
+		}
+		index := p[segment]
+		parentSK := sk
+		sk, err = DeriveChildSK(parentSK, index)
+		// the parent is encoded already, and not needed after its child is derived
+		parentSK.Wipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive secret key from child node at segment %d, index %d: %w", segment+1, index, err)
+		}
+	}
+}
+
+// SecretKeyForCoin derives the secret key at m/12381/coinType/account/index,
+// for BLS chains that follow ERC-2334 with a coin type other than 3600 (eth2).
+func SecretKeyForCoin(seed []byte, coinType, account, index uint32) (*SK, error) {
+	if len(seed) < 32 {
+		return nil, ErrSeedTooShort
+	}
+	return deriveSK(context.Background(), seed, Path{Eth2Purpose, coinType, account, index}, nil)
+}
+
+const (
+	// Eth2Purpose is the ERC-2334 purpose, the first index of every path: the BLS12-381 curve name.
+	Eth2Purpose = 12381
+	// Eth2CoinType is the ERC-2334 coin type of eth2.
+	Eth2CoinType = 3600
+)
+
+// WithdrawalKeyPath returns the ERC-2334 path of the withdrawal key of an eth2 validator account,
+// m/12381/3600/account/0.
+func WithdrawalKeyPath(account uint32) Path {
+	return Path{Eth2Purpose, Eth2CoinType, account, 0}
+}
+
+// SigningKeyPath returns the ERC-2334 path of the signing key of an eth2 validator account,
+// m/12381/3600/account/0/0.
+func SigningKeyPath(account uint32) Path {
+	return WithdrawalKeyPath(account).Child(0)
+}
+
+// DeriveSigningKey derives the signing key of an eth2 validator account, see SigningKeyPath.
+func DeriveSigningKey(seed []byte, account uint32) (*[32]byte, error) {
+	return SecretKeyFromPath(seed, SigningKeyPath(account))
+}
+
+func checkContext(ctx context.Context, segment int) error {
+	err := ctx.Err()
+	// The context timer may not have fired yet while derivation keeps the CPU busy,
+	// so check the deadline itself too.
+	if deadline, ok := ctx.Deadline(); ok && err == nil && !time.Now().Before(deadline) {
+		err = context.DeadlineExceeded
+	}
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w before segment %d: %w", ErrDeadlineExceeded, segment, err)
+	}
+	return fmt.Errorf("derivation aborted before segment %d: %w", segment, err)
+}
+
+// PubKeyDeriver computes the compressed BLS12-381 G1 public key of a secret key.
+// This package does not implement the curve: callers plug in the BLS library of their choice.
+type PubKeyDeriver interface {
+	SKToPK(sk *[32]byte) ([48]byte, error)
+}
+
+// DerivePublicKey derives the secret key at the path, like SecretKeyFromHD,
+// and returns its public key as computed by d. The secret key is wiped afterwards.
+func DerivePublicKey(seed []byte, path string, d PubKeyDeriver) ([48]byte, error) {
+	if d == nil {
+		return [48]byte{}, errors.New("no public key deriver")
+	}
+	sk, err := SecretKeyFromHD(seed, path)
+	if err != nil {
+		return [48]byte{}, err
+	}
+	defer zero(sk[:])
+	pk, err := d.SKToPK(sk)
+	if err != nil {
+		return [48]byte{}, fmt.Errorf("failed to derive public key: %w", err)
+	}
+	return pk, nil
+}
+
+// VerifyExpectedKeys derives the key of every path in expected, and compares it in constant time
+// against the expected key. The result maps each path to whether the derived key matched.
+// This is a regression harness to check an implementation against a frozen set of keys.
+func VerifyExpectedKeys(seed []byte, expected map[string][32]byte) (map[string]bool, error) {
+	out := make(map[string]bool, len(expected))
+	for path, expectedKey := range expected {
+		key, err := SecretKeyFromHD(seed, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key for path %q: %w", path, err)
+		}
+		out[path] = ctEqual32(key, &expectedKey)
+	}
+	return out, nil
+}
@@ -2,9 +2,14 @@ package bls12_381_hd
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math/big"
+	"strings"
 	"testing"
+	"time"
 )
 
 type ERC2334TestCase struct {
@@ -53,6 +58,260 @@ func TestSecretKeyFromHD(t *testing.T) {
 			if !bytes.Equal(gotKey[:], expectedKey) {
 				t.Fatalf("keys differ:\n%x < got\n%x < expected\n", gotKey[:], expectedKey[:])
 			}
+			t.Run("low_memory", func(t *testing.T) {
+				gotKey, err := SecretKeyFromHDWithOptions(context.Background(), seed, tc.Path, &DeriveOptions{LowMemory: true})
+				if err != nil {
+					t.Fatalf("failed to derive key: %v", err)
+				}
+				if !bytes.Equal(gotKey[:], expectedKey) {
+					t.Fatalf("keys differ:\n%x < got\n%x < expected\n", gotKey[:], expectedKey[:])
+				}
+			})
+			t.Run("parallel", func(t *testing.T) {
+				gotKey, err := SecretKeyFromHDWithOptions(context.Background(), seed, tc.Path, &DeriveOptions{Parallel: true})
+				if err != nil {
+					t.Fatalf("failed to derive key: %v", err)
+				}
+				if !bytes.Equal(gotKey[:], expectedKey) {
+					t.Fatalf("keys differ:\n%x < got\n%x < expected\n", gotKey[:], expectedKey[:])
+				}
+			})
 		})
 	}
 }
+
+func TestSecretKeyFromHDContext(t *testing.T) {
+	seed := make([]byte, 32)
+	path := "m" + strings.Repeat("/0", 16)
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Microsecond)
+		defer cancel()
+		_, err := SecretKeyFromHDContext(ctx, seed, path)
+		if !errors.Is(err, ErrDeadlineExceeded) {
+			t.Fatalf("expected deadline error, got %v", err)
+		}
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := SecretKeyFromHDContext(ctx, seed, path)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected cancellation error, got %v", err)
+		}
+		if errors.Is(err, ErrDeadlineExceeded) {
+			t.Fatal("cancellation is not a deadline error")
+		}
+	})
+	t.Run("background", func(t *testing.T) {
+		got, err := SecretKeyFromHDContext(context.Background(), seed, "m/0/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := SecretKeyFromHD(seed, "m/0/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != *expected {
+			t.Fatal("context variant derived a different key")
+		}
+	})
+}
+
+func TestRequireChild(t *testing.T) {
+	seed := make([]byte, 32)
+	if _, err := SecretKeyFromHDWithOptions(context.Background(), seed, "m", nil); err != nil {
+		t.Fatalf("expected master node to be allowed by default: %v", err)
+	}
+	opts := &DeriveOptions{RequireChild: true}
+	if _, err := SecretKeyFromHDWithOptions(context.Background(), seed, "m", opts); !errors.Is(err, ErrNoChildInPath) {
+		t.Fatalf("expected missing child error, got %v", err)
+	}
+	if _, err := SecretKeyFromHDWithOptions(context.Background(), seed, "m/0", opts); err != nil {
+		t.Fatalf("expected child path to be allowed: %v", err)
+	}
+}
+
+func TestSecretKeyFromHDErrors(t *testing.T) {
+	if _, err := SecretKeyFromHD(make([]byte, 31), "m/0"); !errors.Is(err, ErrSeedTooShort) {
+		t.Fatalf("expected short seed error, got %v", err)
+	}
+	if _, err := SecretKeyFromHD(make([]byte, 32), "m/0/m"); !errors.Is(err, ErrUnexpectedMaster) {
+		t.Fatalf("expected unexpected master error, got %v", err)
+	}
+}
+
+func TestSecretKeyFromHDWithKeyInfo(t *testing.T) {
+	seed := make([]byte, 32)
+	expected, err := SecretKeyFromHD(seed, "m/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, err := SecretKeyFromHDWithKeyInfo(seed, "m/0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *same != *expected {
+		t.Fatal("expected empty key info to derive the standard key")
+	}
+	other, err := SecretKeyFromHDWithKeyInfo(seed, "m/0", "some-scheme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *other == *expected {
+		t.Fatal("expected key info to change the derived key")
+	}
+	masterSK, err := DeriveMasterSKWithKeyInfo(seed, "some-scheme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	childSK, err := DeriveChildSK(masterSK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := I2OSP32((*big.Int)(childSK)); got != *other {
+		t.Fatal("expected key info to only apply to the master node")
+	}
+}
+
+func TestSecretKeyFromHDSK(t *testing.T) {
+	seed := make([]byte, 32)
+	sk, err := SecretKeyFromHDSK(seed, "m/12381/3600/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := SecretKeyFromHD(seed, "m/12381/3600/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk.Bytes() != *expected {
+		t.Fatal("key differs")
+	}
+	if _, err := SecretKeyFromHDSK(make([]byte, 31), "m/0"); !errors.Is(err, ErrSeedTooShort) {
+		t.Fatalf("expected short seed error, got %v", err)
+	}
+}
+
+func TestDeriveChain(t *testing.T) {
+	seed := make([]byte, 32)
+	chain, err := DeriveChain(seed, "m/12381/3600/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{"m", "m/12381", "m/12381/3600", "m/12381/3600/0"}
+	if len(chain) != len(paths) {
+		t.Fatalf("expected %d keys, got %d", len(paths), len(chain))
+	}
+	for i, path := range paths {
+		expected, err := SecretKeyFromHD(seed, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *chain[i] != *expected {
+			t.Fatalf("key %d differs from key at %s", i, path)
+		}
+	}
+}
+
+func TestEth2Paths(t *testing.T) {
+	if got := WithdrawalKeyPath(7).String(); got != "m/12381/3600/7/0" {
+		t.Fatalf("unexpected withdrawal key path %s", got)
+	}
+	if got := SigningKeyPath(7).String(); got != "m/12381/3600/7/0/0" {
+		t.Fatalf("unexpected signing key path %s", got)
+	}
+	seed := make([]byte, 32)
+	expected, err := SecretKeyFromHD(seed, "m/12381/3600/7/0/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DeriveSigningKey(seed, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *expected {
+		t.Fatal("signing key differs")
+	}
+}
+
+func TestSecretKeyForCoin(t *testing.T) {
+	seed := make([]byte, 32)
+	sk, err := SecretKeyForCoin(seed, 5, 1, 2)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+	expected, err := SecretKeyFromHD(seed, "m/12381/5/1/2")
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+	if got := I2OSP32((*big.Int)(sk)); got != *expected {
+		t.Fatalf("keys differ:\n%x < got\n%x < expected\n", got[:], expected[:])
+	}
+}
+
+func TestVerifyExpectedKeys(t *testing.T) {
+	seed := make([]byte, 32)
+	match, err := SecretKeyFromHD(seed, "m/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatch, err := SecretKeyFromHD(seed, "m/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := VerifyExpectedKeys(seed, map[string][32]byte{
+		"m/0": *match,
+		"m/2": *mismatch,
+	})
+	if err != nil {
+		t.Fatalf("failed to verify keys: %v", err)
+	}
+	if len(results) != 2 || !results["m/0"] || results["m/2"] {
+		t.Fatalf("unexpected results: %v", results)
+	}
+	if _, err := VerifyExpectedKeys(seed, map[string][32]byte{"m/x": {}}); err == nil {
+		t.Fatal("expected invalid path to fail")
+	}
+}
+
+// stubDeriver is not a curve: it copies the secret key, to check the glue around PubKeyDeriver.
+type stubDeriver struct{}
+
+func (stubDeriver) SKToPK(sk *[32]byte) (out [48]byte, err error) {
+	if *sk == ([32]byte{}) {
+		return out, errors.New("zero key")
+	}
+	copy(out[:], sk[:])
+	return out, nil
+}
+
+func TestDerivePublicKey(t *testing.T) {
+	seed := make([]byte, 32)
+	sk, err := SecretKeyFromHD(seed, "m/12381/3600/0/0/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := DerivePublicKey(seed, "m/12381/3600/0/0/0", stubDeriver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pk[:32], sk[:]) {
+		t.Fatal("expected the derived secret key to be passed to the deriver")
+	}
+	if _, err := DerivePublicKey(seed, "m/x", stubDeriver{}); err == nil {
+		t.Fatal("expected invalid path to be rejected")
+	}
+	if _, err := DerivePublicKey(seed, "m/0", nil); err == nil {
+		t.Fatal("expected missing deriver to be rejected")
+	}
+}
+
+func BenchmarkDeriveSigningKey(b *testing.B) {
+	seed := make([]byte, 32)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DeriveSigningKey(seed, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package bls12_381_hd
+
+// Domain types of the Ethereum consensus specs.
+var (
+	DomainBeaconProposer    = [4]byte{0x00, 0x00, 0x00, 0x00}
+	DomainBeaconAttester    = [4]byte{0x01, 0x00, 0x00, 0x00}
+	DomainRandao            = [4]byte{0x02, 0x00, 0x00, 0x00}
+	DomainDeposit           = [4]byte{0x03, 0x00, 0x00, 0x00}
+	DomainVoluntaryExit     = [4]byte{0x04, 0x00, 0x00, 0x00}
+	DomainSelectionProof    = [4]byte{0x05, 0x00, 0x00, 0x00}
+	DomainAggregateAndProof = [4]byte{0x06, 0x00, 0x00, 0x00}
+	DomainSyncCommittee     = [4]byte{0x07, 0x00, 0x00, 0x00}
+)
+
+// computeForkDataRoot implements compute_fork_data_root:
+// the SSZ hash_tree_root of ForkData(current_version, genesis_validators_root).
+func computeForkDataRoot(forkVersion [4]byte, genesisValidatorsRoot [32]byte) (out [32]byte) {
+	var buf [64]byte
+	// Bytes4 is right-padded to a 32 byte chunk
+	copy(buf[:4], forkVersion[:])
+	copy(buf[32:], genesisValidatorsRoot[:])
+	copy(out[:], SHA256(buf[:]))
+	return out
+}
+
+// ComputeDomain implements compute_domain of the Ethereum consensus specs.
+//
+// https://github.com/ethereum/consensus-specs/blob/dev/specs/phase0/beacon-chain.md#compute_domain
+func ComputeDomain(domainType [4]byte, forkVersion [4]byte, genesisValidatorsRoot [32]byte) (out [32]byte) {
+	forkDataRoot := computeForkDataRoot(forkVersion, genesisValidatorsRoot)
+	copy(out[:4], domainType[:])
+	copy(out[4:], forkDataRoot[:28])
+	return out
+}
+
+// ComputeSigningRoot implements compute_signing_root of the Ethereum consensus specs,
+// given the hash_tree_root of the object to sign:
+// the SSZ hash_tree_root of SigningData(object_root, domain).
+//
+// https://github.com/ethereum/consensus-specs/blob/dev/specs/phase0/beacon-chain.md#compute_signing_root
+func ComputeSigningRoot(objectRoot [32]byte, domain [32]byte) (out [32]byte) {
+	var buf [64]byte
+	copy(buf[:32], objectRoot[:])
+	copy(buf[32:], domain[:])
+	copy(out[:], SHA256(buf[:]))
+	return out
+}
+
+// Withdrawal credential prefixes of the Ethereum consensus specs.
+const (
+	BLSWithdrawalPrefix         = 0x00
+	Eth1AddressWithdrawalPrefix = 0x01
+)
+
+// WithdrawalCredentialsBLS computes the 0x00 type withdrawal credentials of a BLS withdrawal public key:
+// BLS_WITHDRAWAL_PREFIX || SHA256(pubkey)[1:]
+func WithdrawalCredentialsBLS(pubkey [48]byte) (out [32]byte) {
+	copy(out[:], SHA256(pubkey[:]))
+	out[0] = BLSWithdrawalPrefix
+	return out
+}
+
+// WithdrawalCredentialsEth1 computes the 0x01 type withdrawal credentials of an execution-layer address:
+// ETH1_ADDRESS_WITHDRAWAL_PREFIX || 11 zero bytes || address
+func WithdrawalCredentialsEth1(addr [20]byte) (out [32]byte) {
+	out[0] = Eth1AddressWithdrawalPrefix
+	copy(out[12:], addr[:])
+	return out
+}
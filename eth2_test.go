@@ -0,0 +1,67 @@
+package bls12_381_hd
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestComputeDomain(t *testing.T) {
+	// Deposits are signed with the genesis fork version and an empty genesis validators root.
+	// Mainnet deposit domain, as used by the staking-deposit-cli and the deposit contract tooling.
+	got := ComputeDomain(DomainDeposit, [4]byte{}, [32]byte{})
+	expected := "03000000f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a9"
+	if hex.EncodeToString(got[:]) != expected {
+		t.Fatalf("got %x but expected %s", got[:], expected)
+	}
+	// Fork version and genesis validators root must both affect the domain.
+	a := ComputeDomain(DomainDeposit, [4]byte{0x00, 0x00, 0x10, 0x20}, [32]byte{})
+	b := ComputeDomain(DomainDeposit, [4]byte{}, [32]byte{0x01})
+	if a == got || b == got || a == b {
+		t.Fatal("expected different fork data to produce different domains")
+	}
+	if [4]byte(a[:4]) != DomainDeposit {
+		t.Fatalf("expected domain type prefix, got %x", a[:4])
+	}
+}
+
+func TestComputeSigningRoot(t *testing.T) {
+	// hash_tree_root of a container with two zero Bytes32 fields is the zero-hash at depth 1
+	got := ComputeSigningRoot([32]byte{}, [32]byte{})
+	expected := "f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a92759fb4b"
+	if hex.EncodeToString(got[:]) != expected {
+		t.Fatalf("got %x but expected %s", got[:], expected)
+	}
+	var objectRoot, domain [32]byte
+	objectRoot[0] = 1
+	domain[0] = 2
+	got = ComputeSigningRoot(objectRoot, domain)
+	want := SHA256(append(objectRoot[:], domain[:]...))
+	if hex.EncodeToString(got[:]) != hex.EncodeToString(want) {
+		t.Fatalf("got %x but expected %x", got[:], want)
+	}
+}
+
+func TestWithdrawalCredentialsBLS(t *testing.T) {
+	// pubkey of the EIP-2335 test keystore
+	pubkey, err := hex.DecodeString("9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := WithdrawalCredentialsBLS([48]byte(pubkey))
+	expected := "00c6758728998bf4d7597533beaa24a1feece5d91d003b5511ed3a1fa090d073"
+	if hex.EncodeToString(got[:]) != expected {
+		t.Fatalf("got %x but expected %s", got[:], expected)
+	}
+}
+
+func TestWithdrawalCredentialsEth1(t *testing.T) {
+	var addr [20]byte
+	for i := range addr {
+		addr[i] = byte(i + 1)
+	}
+	got := WithdrawalCredentialsEth1(addr)
+	expected := "010000000000000000000000" + hex.EncodeToString(addr[:])
+	if hex.EncodeToString(got[:]) != expected {
+		t.Fatalf("got %x but expected %s", got[:], expected)
+	}
+}
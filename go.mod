@@ -2,4 +2,7 @@ module github.com/protolambda/bls12-381-hd
 
 go 1.21
 
-require golang.org/x/crypto v0.19.0
+require (
+	golang.org/x/crypto v0.19.0
+	golang.org/x/text v0.14.0
+)
@@ -0,0 +1,280 @@
+package bls12_381_hd
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrKeystoreChecksum is returned when the checksum of a keystore does not match,
+// which typically means the password is wrong.
+var ErrKeystoreChecksum = errors.New("keystore checksum mismatch, wrong password?")
+
+const keystoreVersion = 4
+
+// pbkdf2 parameters, matching the EIP-2335 keystore test vector.
+const (
+	pbkdf2C     = 1 << 18
+	pbkdf2DKLen = 32
+	pbkdf2PRF   = "hmac-sha256"
+
+	// pbkdf2MaxC bounds the CPU time of decrypting an untrusted keystore.
+	pbkdf2MaxC = 1 << 22
+)
+
+type pbkdf2Params struct {
+	DKLen int      `json:"dklen"`
+	C     int      `json:"c"`
+	PRF   string   `json:"prf"`
+	Salt  hexBytes `json:"salt"`
+}
+
+func (p *pbkdf2Params) deriveKey(password []byte) ([]byte, error) {
+	if p.DKLen != 32 {
+		return nil, fmt.Errorf("unsupported pbkdf2 dklen %d, expected 32", p.DKLen)
+	}
+	if p.PRF != pbkdf2PRF {
+		return nil, fmt.Errorf("unsupported pbkdf2 prf %q", p.PRF)
+	}
+	if p.C < 1 || p.C > pbkdf2MaxC {
+		return nil, fmt.Errorf("unsupported pbkdf2 c %d, expected 1 to %d", p.C, pbkdf2MaxC)
+	}
+	return pbkdf2.Key(password, p.Salt, p.C, p.DKLen, sha256.New), nil
+}
+
+type keystoreCipherParams struct {
+	IV hexBytes `json:"iv"`
+}
+
+// keystoreModule is a kdf, checksum or cipher module of an EIP-2335 keystore.
+type keystoreModule struct {
+	Function string          `json:"function"`
+	Params   json.RawMessage `json:"params"`
+	Message  hexBytes        `json:"message"`
+}
+
+type keystoreCrypto struct {
+	KDF      keystoreModule `json:"kdf"`
+	Checksum keystoreModule `json:"checksum"`
+	Cipher   keystoreModule `json:"cipher"`
+}
+
+type keystore struct {
+	Crypto      keystoreCrypto `json:"crypto"`
+	Description string         `json:"description"`
+	Pubkey      string         `json:"pubkey"`
+	Path        string         `json:"path"`
+	UUID        string         `json:"uuid"`
+	Version     int            `json:"version"`
+}
+
+type keystoreConfig struct {
+	pbkdf2      bool
+	description string
+	pubkey      []byte
+}
+
+// KeystoreOption configures EncryptKeystore.
+type KeystoreOption func(cfg *keystoreConfig)
+
+// KeystorePBKDF2 selects the pbkdf2 KDF instead of the default scrypt KDF.
+func KeystorePBKDF2() KeystoreOption {
+	return func(cfg *keystoreConfig) {
+		cfg.pbkdf2 = true
+	}
+}
+
+// KeystoreDescription sets the description field of the keystore.
+func KeystoreDescription(description string) KeystoreOption {
+	return func(cfg *keystoreConfig) {
+		cfg.description = description
+	}
+}
+
+// KeystorePubkey sets the pubkey field of the keystore.
+// This package does not implement the BLS12-381 curve, so the caller computes the public key.
+func KeystorePubkey(pubkey []byte) KeystoreOption {
+	return func(cfg *keystoreConfig) {
+		cfg.pubkey = pubkey
+	}
+}
+
+// keystorePassword normalizes the password as specified in EIP-2335:
+// NFKD normalization, followed by stripping the C0, C1 and Delete control codes.
+func keystorePassword(password string) []byte {
+	return []byte(strings.Map(func(c rune) rune {
+		if c < 0x20 || (c >= 0x7f && c <= 0x9f) {
+			return -1
+		}
+		return c
+	}, norm.NFKD.String(password)))
+}
+
+// EncryptKeystore encrypts a secret key into an EIP-2335 (version 4) keystore JSON document,
+// readable by other Ethereum tooling. The path is embedded verbatim.
+//
+// The scrypt KDF is used by default, see KeystorePBKDF2 for the alternative.
+// The cipher is AES-128-CTR, with a SHA256 checksum as specified.
+func EncryptKeystore(sk *[32]byte, password string, path string, opts ...KeystoreOption) ([]byte, error) {
+	return encryptKeystore(sk, password, path, rand.Reader, opts...)
+}
+
+func encryptKeystore(sk *[32]byte, password string, path string, rng io.Reader, opts ...KeystoreOption) ([]byte, error) {
+	if v := osToIP(sk[:]); v.Sign() == 0 || v.Cmp(r) >= 0 {
+		return nil, fmt.Errorf("invalid secret key: %w", ErrSKOutOfRange)
+	}
+	var cfg keystoreConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	pw := keystorePassword(password)
+	defer zero(pw)
+	var kdf keystoreModule
+	var dk []byte
+	if cfg.pbkdf2 {
+		salt := make([]byte, 32)
+		if _, err := io.ReadFull(rng, salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		params := &pbkdf2Params{DKLen: pbkdf2DKLen, C: pbkdf2C, PRF: pbkdf2PRF, Salt: salt}
+		var err error
+		if dk, err = params.deriveKey(pw); err != nil {
+			return nil, fmt.Errorf("failed to derive decryption key: %w", err)
+		}
+		kdf.Function = "pbkdf2"
+		kdf.Params, err = json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		params, err := newScryptParams(rng)
+		if err != nil {
+			return nil, err
+		}
+		if dk, err = params.deriveKey(pw); err != nil {
+			return nil, fmt.Errorf("failed to derive decryption key: %w", err)
+		}
+		kdf.Function = "scrypt"
+		kdf.Params, err = json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer zero(dk)
+	iv := make([]byte, 16)
+	if _, err := io.ReadFull(rng, iv); err != nil {
+		return nil, fmt.Errorf("failed to generate iv: %w", err)
+	}
+	cipherText, err := aes128CTR(dk, iv, sk[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt key: %w", err)
+	}
+	cipherParams, err := json.Marshal(&keystoreCipherParams{IV: iv})
+	if err != nil {
+		return nil, err
+	}
+	uuid, err := newUUID(rng)
+	if err != nil {
+		return nil, err
+	}
+	sum := checksum(dk, cipherText)
+	out := keystore{
+		Crypto: keystoreCrypto{
+			KDF:      kdf,
+			Checksum: keystoreModule{Function: "sha256", Params: json.RawMessage("{}"), Message: sum[:]},
+			Cipher:   keystoreModule{Function: "aes-128-ctr", Params: cipherParams, Message: cipherText},
+		},
+		Description: cfg.description,
+		Pubkey:      hex.EncodeToString(cfg.pubkey),
+		Path:        path,
+		UUID:        uuid,
+		Version:     keystoreVersion,
+	}
+	return json.Marshal(&out)
+}
+
+// newUUID generates a random (version 4) UUID, as used to identify keystores.
+func newUUID(rng io.Reader) (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(rng, b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate uuid: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// DecryptKeystore decrypts an EIP-2335 (version 4) keystore JSON document,
+// with either the scrypt or pbkdf2 KDF, and returns the secret key.
+// The checksum is verified before decrypting: ErrKeystoreChecksum is returned if the password is wrong.
+func DecryptKeystore(keystoreJSON []byte, password string) (*[32]byte, error) {
+	var in keystore
+	if err := json.Unmarshal(keystoreJSON, &in); err != nil {
+		return nil, fmt.Errorf("failed to decode keystore: %w", err)
+	}
+	if in.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %d", in.Version)
+	}
+	if f := in.Crypto.Checksum.Function; f != "sha256" {
+		return nil, fmt.Errorf("unsupported checksum function %q", f)
+	}
+	if f := in.Crypto.Cipher.Function; f != "aes-128-ctr" {
+		return nil, fmt.Errorf("unsupported cipher function %q", f)
+	}
+	var cipherParams keystoreCipherParams
+	if err := json.Unmarshal(in.Crypto.Cipher.Params, &cipherParams); err != nil {
+		return nil, fmt.Errorf("failed to decode cipher params: %w", err)
+	}
+	cipherText := in.Crypto.Cipher.Message
+	if len(cipherText) != 32 {
+		return nil, fmt.Errorf("expected 32 byte cipher text, got %d", len(cipherText))
+	}
+	pw := keystorePassword(password)
+	defer zero(pw)
+	var dk []byte
+	switch f := in.Crypto.KDF.Function; f {
+	case "scrypt":
+		var params scryptParams
+		if err := json.Unmarshal(in.Crypto.KDF.Params, &params); err != nil {
+			return nil, fmt.Errorf("failed to decode scrypt params: %w", err)
+		}
+		var err error
+		if dk, err = params.deriveKey(pw); err != nil {
+			return nil, fmt.Errorf("failed to derive decryption key: %w", err)
+		}
+	case "pbkdf2":
+		var params pbkdf2Params
+		if err := json.Unmarshal(in.Crypto.KDF.Params, &params); err != nil {
+			return nil, fmt.Errorf("failed to decode pbkdf2 params: %w", err)
+		}
+		var err error
+		if dk, err = params.deriveKey(pw); err != nil {
+			return nil, fmt.Errorf("failed to derive decryption key: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kdf %q", f)
+	}
+	defer zero(dk)
+	sum := checksum(dk, cipherText)
+	if len(in.Crypto.Checksum.Message) != 32 || !ctEqual32(&sum, (*[32]byte)(in.Crypto.Checksum.Message)) {
+		return nil, ErrKeystoreChecksum
+	}
+	sk32, err := aes128CTR(dk, cipherParams.IV, cipherText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key: %w", err)
+	}
+	out := [32]byte(sk32)
+	zero(sk32)
+	if v := osToIP(out[:]); v.Sign() == 0 || v.Cmp(r) >= 0 {
+		return nil, fmt.Errorf("decrypted key is not a valid secret key: %w", ErrSKOutOfRange)
+	}
+	return &out, nil
+}
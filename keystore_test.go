@@ -0,0 +1,196 @@
+package bls12_381_hd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// EIP-2335 test vectors, https://eips.ethereum.org/EIPS/eip-2335#test-cases
+const (
+	keystoreTestPassword = "𝔱𝔢𝔰𝔱𝔭𝔞𝔰𝔰𝔴𝔬𝔯𝔡🔑"
+	keystoreTestSecret   = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	keystoreTestSalt     = "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"
+	keystoreTestIV       = "264daa3f303d7259501c93d997d84fe6"
+	keystoreTestUUID     = "1d85ae2035c5461198e8aa14a633906f"
+	keystoreTestPubkey   = "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07"
+	keystoreTestPath     = "m/12381/60/3141592653/589793238"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEncryptKeystoreVectors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		opts       []KeystoreOption
+		kdf        string
+		checksum   string
+		cipherText string
+	}{
+		{
+			name:       "scrypt",
+			kdf:        "scrypt",
+			checksum:   "d2217fe5f3e9a1e34581ef8a78f7c9928e436d36dacc5e846690a5581e8ea484",
+			cipherText: "06ae90d55fe0a6e9c5c3bc5b170827b2e5cce3929ed3f116c2811e6366dfe20f",
+		},
+		{
+			name:       "pbkdf2",
+			opts:       []KeystoreOption{KeystorePBKDF2()},
+			kdf:        "pbkdf2",
+			checksum:   "8a9f5d9912ed7e75ea794bc5a89bca5f193721d30868ade6f73043c6ea6febf1",
+			cipherText: "cee03fde2af33149775b7223e7845e4fb2c8ae1792e5f99fe9ecf474cc8c16ad",
+		},
+	}
+	secret := [32]byte(mustHex(t, keystoreTestSecret))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var rng bytes.Buffer
+			rng.Write(mustHex(t, keystoreTestSalt))
+			rng.Write(mustHex(t, keystoreTestIV))
+			rng.Write(mustHex(t, keystoreTestUUID))
+			opts := append(tc.opts, KeystorePubkey(mustHex(t, keystoreTestPubkey)))
+			data, err := encryptKeystore(&secret, keystoreTestPassword, keystoreTestPath, &rng, opts...)
+			if err != nil {
+				t.Fatalf("failed to encrypt: %v", err)
+			}
+			var ks keystore
+			if err := json.Unmarshal(data, &ks); err != nil {
+				t.Fatal(err)
+			}
+			if ks.Version != 4 || ks.Path != keystoreTestPath || ks.Pubkey != keystoreTestPubkey {
+				t.Fatalf("unexpected keystore fields: %s", data)
+			}
+			if ks.UUID != "1d85ae20-35c5-4611-98e8-aa14a633906f" {
+				t.Fatalf("unexpected uuid %s", ks.UUID)
+			}
+			if ks.Crypto.KDF.Function != tc.kdf || ks.Crypto.Cipher.Function != "aes-128-ctr" {
+				t.Fatalf("unexpected modules: %s", data)
+			}
+			if got := hex.EncodeToString(ks.Crypto.Checksum.Message); got != tc.checksum {
+				t.Fatalf("got checksum %s, expected %s", got, tc.checksum)
+			}
+			if got := hex.EncodeToString(ks.Crypto.Cipher.Message); got != tc.cipherText {
+				t.Fatalf("got cipher text %s, expected %s", got, tc.cipherText)
+			}
+		})
+	}
+}
+
+func TestKeystorePassword(t *testing.T) {
+	if got := string(keystorePassword(keystoreTestPassword)); got != "testpassword🔑" {
+		t.Fatalf("unexpected normalized password %q", got)
+	}
+	if got := string(keystorePassword("a\x00b\x7fc\u0085d\n")); got != "abcd" {
+		t.Fatalf("expected control codes to be stripped, got %q", got)
+	}
+}
+
+func TestEncryptKeystoreInvalidKey(t *testing.T) {
+	var sk [32]byte
+	if _, err := EncryptKeystore(&sk, "password", "m/12381/3600/0/0/0"); err == nil {
+		t.Fatal("expected zero key to be rejected")
+	}
+}
+
+// keystoreTestScrypt is the scrypt test vector keystore of EIP-2335.
+const keystoreTestScrypt = `{
+    "crypto": {
+        "kdf": {
+            "function": "scrypt",
+            "params": {
+                "dklen": 32,
+                "n": 262144,
+                "p": 1,
+                "r": 8,
+                "salt": "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"
+            },
+            "message": ""
+        },
+        "checksum": {
+            "function": "sha256",
+            "params": {},
+            "message": "d2217fe5f3e9a1e34581ef8a78f7c9928e436d36dacc5e846690a5581e8ea484"
+        },
+        "cipher": {
+            "function": "aes-128-ctr",
+            "params": {
+                "iv": "264daa3f303d7259501c93d997d84fe6"
+            },
+            "message": "06ae90d55fe0a6e9c5c3bc5b170827b2e5cce3929ed3f116c2811e6366dfe20f"
+        }
+    },
+    "description": "This is a test keystore that uses scrypt to secure the secret.",
+    "pubkey": "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07",
+    "path": "m/12381/60/3141592653/589793238",
+    "uuid": "1d85ae20-35c5-4611-98e8-aa14a633906f",
+    "version": 4
+}`
+
+func TestDecryptKeystoreVector(t *testing.T) {
+	sk, err := DecryptKeystore([]byte(keystoreTestScrypt), keystoreTestPassword)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+	if got := hex.EncodeToString(sk[:]); got != keystoreTestSecret {
+		t.Fatalf("got secret %s, expected %s", got, keystoreTestSecret)
+	}
+	if _, err := DecryptKeystore([]byte(keystoreTestScrypt), "testpassword"); !errors.Is(err, ErrKeystoreChecksum) {
+		t.Fatalf("expected checksum error for wrong password, got %v", err)
+	}
+}
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	sk, err := SecretKeyFromHD(make([]byte, 32), "m/12381/3600/0/0/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := EncryptKeystore(sk, "testpassword", "m/12381/3600/0/0/0", KeystorePBKDF2())
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	got, err := DecryptKeystore(data, "testpassword")
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+	if *got != *sk {
+		t.Fatal("decrypted key differs")
+	}
+	if _, err := DecryptKeystore(data, "wrongpassword"); !errors.Is(err, ErrKeystoreChecksum) {
+		t.Fatalf("expected checksum error for wrong password, got %v", err)
+	}
+	if _, err := DecryptKeystore([]byte("{}"), "testpassword"); err == nil || errors.Is(err, ErrKeystoreChecksum) {
+		t.Fatalf("expected malformed keystore error, got %v", err)
+	}
+}
+
+func TestDecryptKeystoreKDFCost(t *testing.T) {
+	for _, tc := range []struct{ from, to string }{
+		{`"n": 262144`, `"n": 2147483648`},
+		{`"n": 262144`, `"n": 262143`},
+		{`"n": 262144`, `"n": 0`},
+		{`"r": 8`, `"r": 255`},
+		{`"p": 1`, `"p": 255`},
+		{`"p": 1`, `"p": 0`},
+	} {
+		keystoreJSON := strings.Replace(keystoreTestScrypt, tc.from, tc.to, 1)
+		// rejected before the KDF runs, so not reported as a wrong password
+		if _, err := DecryptKeystore([]byte(keystoreJSON), keystoreTestPassword); err == nil || errors.Is(err, ErrKeystoreChecksum) {
+			t.Fatalf("expected scrypt %s to be rejected, got %v", tc.to, err)
+		}
+	}
+	pbkdf2JSON := strings.Replace(strings.Replace(keystoreTestScrypt, `"scrypt"`, `"pbkdf2"`, 1),
+		`"n": 262144,`, `"c": 4294967296, "prf": "hmac-sha256",`, 1)
+	if _, err := DecryptKeystore([]byte(pbkdf2JSON), keystoreTestPassword); err == nil || !strings.Contains(err.Error(), "pbkdf2 c") {
+		t.Fatalf("expected pbkdf2 c to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package bls12_381_hd
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// ErrMnemonicWordCount is returned for mnemonics that do not have 12, 15, 18, 21 or 24 words.
+	ErrMnemonicWordCount = errors.New("invalid mnemonic word count")
+	// ErrMnemonicUnknownWord is returned for mnemonics with a word that is not in the BIP-39 English wordlist.
+	ErrMnemonicUnknownWord = errors.New("unknown mnemonic word")
+	// ErrMnemonicChecksum is returned for mnemonics of which the checksum does not match the entropy,
+	// typically due to a typo in one of the words.
+	ErrMnemonicChecksum = errors.New("invalid mnemonic checksum")
+)
+
+// bip39English is the BIP-39 English wordlist,
+// https://github.com/bitcoin/bips/blob/master/bip-0039/english.txt
+//
+//go:embed bip39_english.txt
+var bip39English string
+
+// bip39EnglishIndices maps each word of the wordlist to its 11 bit index.
+var bip39EnglishIndices = func() map[string]uint16 {
+	words := strings.Fields(bip39English)
+	if len(words) != 2048 {
+		panic(fmt.Errorf("expected 2048 words in wordlist, got %d", len(words)))
+	}
+	out := make(map[string]uint16, len(words))
+	for i, w := range words {
+		out[w] = uint16(i)
+	}
+	return out
+}()
+
+// mnemonicWords NFKD normalizes the mnemonic, splits it into words on any whitespace,
+// and checks the word count.
+func mnemonicWords(mnemonic string) ([]string, error) {
+	words := strings.Fields(norm.NFKD.String(mnemonic))
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+		return words, nil
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrMnemonicWordCount, len(words))
+	}
+}
+
+// ValidateMnemonic checks that the mnemonic consists of 12, 15, 18, 21 or 24 words
+// of the BIP-39 English wordlist, and that the checksum matches the entropy it encodes.
+// Leading, trailing and repeated whitespace between words is ignored.
+//
+// The error wraps ErrMnemonicWordCount, ErrMnemonicUnknownWord or ErrMnemonicChecksum.
+func ValidateMnemonic(mnemonic string) error {
+	words, err := mnemonicWords(mnemonic)
+	if err != nil {
+		return err
+	}
+	// Each word encodes 11 bits: ENT bits of entropy, followed by CS = ENT / 32 bits of checksum.
+	bits := make([]byte, (len(words)*11+7)/8)
+	for i, w := range words {
+		index, ok := bip39EnglishIndices[w]
+		if !ok {
+			return fmt.Errorf("%w at position %d: %q", ErrMnemonicUnknownWord, i, w)
+		}
+		for j := 0; j < 11; j++ {
+			if index&(1<<(10-j)) != 0 {
+				pos := i*11 + j
+				bits[pos/8] |= 1 << (7 - pos%8)
+			}
+		}
+	}
+	checksumBits := len(words) * 11 / 33
+	entropy := bits[:checksumBits*4]
+	checksum := sha256.Sum256(entropy)
+	// the checksum bits are the first CS bits of SHA256(entropy), at most 8
+	mask := byte(0xff) << (8 - checksumBits)
+	if checksum[0]&mask != bits[len(entropy)]&mask {
+		return ErrMnemonicChecksum
+	}
+	return nil
+}
+
+// MnemonicOptions configures SeedFromMnemonicWithOptions.
+type MnemonicOptions struct {
+	// SkipChecksum skips ValidateMnemonic, to derive seeds of mnemonics that do not use the
+	// BIP-39 English wordlist. The word count is still checked.
+	SkipChecksum bool
+}
+
+// SeedFromMnemonic implements the BIP-39 mnemonic to seed function:
+// PBKDF2-HMAC-SHA512 with 2048 iterations over the mnemonic, salted with "mnemonic" + passphrase,
+// with both NFKD normalized. The result is the 64 byte seed.
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki#from-mnemonic-to-seed
+//
+// The mnemonic is first checked with ValidateMnemonic.
+// Words may be separated by any whitespace, which is normalized to single spaces.
+func SeedFromMnemonic(mnemonic string, passphrase string) (Seed, error) {
+	return SeedFromMnemonicWithOptions(mnemonic, passphrase, nil)
+}
+
+// SeedFromMnemonicWithOptions is SeedFromMnemonic with options, which may be nil.
+func SeedFromMnemonicWithOptions(mnemonic string, passphrase string, opts *MnemonicOptions) (Seed, error) {
+	if opts == nil || !opts.SkipChecksum {
+		if err := ValidateMnemonic(mnemonic); err != nil {
+			return nil, err
+		}
+	}
+	words, err := mnemonicWords(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	sentence := strings.Join(words, " ")
+	salt := "mnemonic" + norm.NFKD.String(passphrase)
+	return pbkdf2.Key([]byte(sentence), []byte(salt), 2048, 64, sha512.New), nil
+}
+
+// SecretKeyFromMnemonic derives the seed of a BIP-39 mnemonic, see SeedFromMnemonic,
+// and then derives the secret key at the given path, see SecretKeyFromHD.
+func SecretKeyFromMnemonic(mnemonic, passphrase, path string) (*[32]byte, error) {
+	seed, err := SeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return SecretKeyFromHD(seed, path)
+}
@@ -0,0 +1,133 @@
+package bls12_381_hd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type MnemonicTestCase struct {
+	Mnemonic   string
+	Passphrase string
+	Seed       string
+}
+
+func TestSeedFromMnemonic(t *testing.T) {
+	testCases := []MnemonicTestCase{
+		// BIP-39 test vectors: https://github.com/trezor/python-mnemonic/blob/master/vectors.json
+		{
+			Mnemonic:   "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			Passphrase: "TREZOR",
+			Seed:       "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			Mnemonic:   "legal winner thank year wave sausage worth useful legal winner thank yellow",
+			Passphrase: "TREZOR",
+			Seed:       "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			Mnemonic:   "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			Passphrase: "TREZOR",
+			Seed:       "ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		// The mnemonic of the ERC-2334 tests
+		{
+			Mnemonic:   "test test test test test test test test test test test junk",
+			Passphrase: "",
+			Seed:       "9dfc3c64c2f8bede1533b6a79f8570e5943e0b8fd1cf77107adf7b72cef42185d564a3aee24cab43f80e3c4538087d70fc824eabbad596a23c97b6ee8322ccc0",
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case_%d", i), func(t *testing.T) {
+			seed, err := SeedFromMnemonic(tc.Mnemonic, tc.Passphrase)
+			if err != nil {
+				t.Fatalf("failed to derive seed: %v", err)
+			}
+			expected, err := hex.DecodeString(tc.Seed)
+			if err != nil {
+				t.Fatalf("invalid test seed: %v", err)
+			}
+			if !bytes.Equal(seed, expected) {
+				t.Fatalf("seeds differ:\n%x < got\n%x < expected\n", seed, expected)
+			}
+		})
+	}
+}
+
+func TestSeedFromMnemonicNormalization(t *testing.T) {
+	const mnemonic = "test test test test test test test test test test test junk"
+	expected, err := SeedFromMnemonic(mnemonic, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fullwidth letters decompose to ASCII under NFKD
+	got, err := SeedFromMnemonic(mnemonic, "ｔｅｓｔ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Fatal("expected passphrase to be NFKD normalized")
+	}
+	got, err = SeedFromMnemonic("  test test test test test test\ttest test test test test  junk\n", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Fatal("expected whitespace to be normalized")
+	}
+	if _, err := SeedFromMnemonic("test test test test test test test test test test test", ""); err == nil {
+		t.Fatal("expected 11 words to be rejected")
+	}
+}
+
+func TestSecretKeyFromMnemonic(t *testing.T) {
+	key, err := SecretKeyFromMnemonic("test test test test test test test test test test test junk", "", "m/12381/3600/0/0/0")
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+	// same as the ERC-2334 test case
+	if hex.EncodeToString(key[:]) != "14e2cda5e3fe2e34de7fa86a4a693dd09d0b2cfe894bb0313f4af6fc4f45de22" {
+		t.Fatalf("unexpected key %x", key[:])
+	}
+}
+
+func TestValidateMnemonic(t *testing.T) {
+	valid := []string{
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		"test test test test test test test test test test test junk",
+		// 24 words, 8 checksum bits
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+		"  test test test   test test test test test test test test\tjunk \n",
+	}
+	for _, m := range valid {
+		if err := ValidateMnemonic(m); err != nil {
+			t.Fatalf("expected %q to be valid: %v", m, err)
+		}
+	}
+	testCases := []struct {
+		Mnemonic string
+		Err      error
+	}{
+		{"test test test test test test test test test test test test", ErrMnemonicChecksum},
+		{"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", ErrMnemonicChecksum},
+		{"test test test test test test test test test test test junkk", ErrMnemonicUnknownWord},
+		{"test test test test test test test test test test junk", ErrMnemonicWordCount},
+		{"", ErrMnemonicWordCount},
+	}
+	for _, tc := range testCases {
+		if err := ValidateMnemonic(tc.Mnemonic); !errors.Is(err, tc.Err) {
+			t.Fatalf("%q: expected %v, got %v", tc.Mnemonic, tc.Err, err)
+		}
+	}
+	if _, err := SeedFromMnemonic("test test test test test test test test test test test test", ""); !errors.Is(err, ErrMnemonicChecksum) {
+		t.Fatalf("expected seed derivation to validate the checksum, got %v", err)
+	}
+	opts := &MnemonicOptions{SkipChecksum: true}
+	if _, err := SeedFromMnemonicWithOptions("test test test test test test test test test test test test", "", opts); err != nil {
+		t.Fatalf("expected checksum to be skipped: %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package bls12_381_hd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is an ERC-2334 path: the indices of the child nodes below the master node.
+// The master node "m" is always the root, so it is implicit: an empty Path is just "m".
+type Path []uint32
+
+// String formats the path, e.g. "m/12381/3600/0/0".
+func (p Path) String() string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, index := range p {
+		b.WriteString("/")
+		b.WriteString(strconv.FormatUint(uint64(index), 10))
+	}
+	return b.String()
+}
+
+// Child returns a new path, to the child node at the given index.
+// The receiver is not modified.
+func (p Path) Child(index uint32) Path {
+	out := make(Path, len(p), len(p)+1)
+	copy(out, p)
+	return append(out, index)
+}
+
+// Parent returns a new path, to the parent node.
+// The master node has no parent.
+func (p Path) Parent() (Path, error) {
+	if len(p) == 0 {
+		return nil, errors.New("master node has no parent")
+	}
+	out := make(Path, len(p)-1)
+	copy(out, p)
+	return out, nil
+}
+
+var (
+	// ErrEmptyPath is returned for an empty path string.
+	ErrEmptyPath = errors.New("path must not be empty")
+	// ErrMissingMaster is returned for a path that does not start with the master node "m".
+	ErrMissingMaster = errors.New("missing master node")
+	// ErrUnexpectedMaster is returned for a path with the master node "m" after the first segment.
+	ErrUnexpectedMaster = errors.New("unexpected master node")
+	// ErrInvalidSegment is returned for a child segment that is not a canonical base-10 uint32.
+	ErrInvalidSegment = errors.New("invalid child node")
+	// ErrIndexOutOfRange is returned, together with ErrInvalidSegment, for a child index of 2**32 or more.
+	ErrIndexOutOfRange = errors.New("child index out of range")
+)
+
+// PathError describes a malformed segment of an ERC-2334 path.
+type PathError struct {
+	// Path is the full path that failed to parse.
+	Path string
+	// Segment is the index of the offending segment, with the master node at segment 0.
+	Segment int
+	// Offset is the byte offset of the offending segment in Path.
+	Offset int
+	// Err describes what is wrong with the segment.
+	Err error
+}
+
+func (e *PathError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// Indicator returns the path, and a second line with carets under the offending segment:
+//
+//	m/12381/abc/0
+//	        ^^^
+func (e *PathError) Indicator() string {
+	width := len(strings.SplitN(e.Path[e.Offset:], "/", 2)[0])
+	if width == 0 {
+		width = 1
+	}
+	return e.Path + "\n" + strings.Repeat(" ", e.Offset) + strings.Repeat("^", width)
+}
+
+// ParsePath parses an ERC-2334 path: the master node "m", followed by child indices, separated by "/".
+// Child indices are base-10 uint32 values, without sign or leading zeros.
+// Malformed paths result in a *PathError.
+func ParsePath(path string) (Path, error) {
+	if path == "" {
+		return nil, &PathError{Path: path, Err: ErrEmptyPath}
+	}
+	segments := strings.Split(path, "/")
+	indices := make(Path, 0, len(segments)-1)
+	offset := 0
+	for i, seg := range segments {
+		fail := func(err error) error {
+			return &PathError{Path: path, Segment: i, Offset: offset, Err: err}
+		}
+		if seg == "" {
+			if i == 0 {
+				return nil, fail(fmt.Errorf("%w at segment 0", ErrMissingMaster))
+			}
+			return nil, fail(fmt.Errorf("%w at segment %d: segment is empty", ErrInvalidSegment, i))
+		}
+		if seg == "m" {
+			if i != 0 {
+				return nil, fail(fmt.Errorf("%w in segment %d", ErrUnexpectedMaster, i))
+			}
+		} else {
+			if i == 0 {
+				return nil, fail(fmt.Errorf("%w at segment 0", ErrMissingMaster))
+			}
+			// Only the canonical form of an index is accepted, so that a path string identifies a single key.
+			if strings.TrimSpace(seg) != seg {
+				return nil, fail(fmt.Errorf("%w at segment %d, value %q: surrounding whitespace", ErrInvalidSegment, i, seg))
+			}
+			if seg[0] == '+' || seg[0] == '-' {
+				return nil, fail(fmt.Errorf("%w at segment %d, value %q: sign", ErrInvalidSegment, i, seg))
+			}
+			if len(seg) > 1 && seg[0] == '0' {
+				return nil, fail(fmt.Errorf("%w at segment %d, value %q: leading zero", ErrInvalidSegment, i, seg))
+			}
+			index, err := strconv.ParseUint(seg, 10, 32)
+			if errors.Is(err, strconv.ErrRange) {
+				return nil, fail(fmt.Errorf("%w at segment %d, value %q: %w", ErrInvalidSegment, i, seg, ErrIndexOutOfRange))
+			}
+			if err != nil {
+				return nil, fail(fmt.Errorf("%w at segment %d, value %q: %w", ErrInvalidSegment, i, seg, err))
+			}
+			indices = append(indices, uint32(index))
+		}
+		offset += len(seg) + 1
+	}
+	return indices, nil
+}
+
+// ExpandTemplate substitutes the index for the "{}" placeholder in a path template,
+// e.g. "m/12381/3600/{}/0/0", and checks that the result is a valid path.
+func ExpandTemplate(template string, index uint32) (string, error) {
+	if n := strings.Count(template, "{}"); n != 1 {
+		return "", fmt.Errorf("expected one {} placeholder in path template, got %d", n)
+	}
+	path := strings.Replace(template, "{}", strconv.FormatUint(uint64(index), 10), 1)
+	if _, err := ParsePath(path); err != nil {
+		return "", fmt.Errorf("invalid path template %q: %w", template, err)
+	}
+	return path, nil
+}
+
+// MaxPathRange is the maximum number of paths that ExpandPathRange expands a template into.
+const MaxPathRange = 1 << 16
+
+// ExpandPathRange expands a path template with one {start..end} range segment, e.g. "m/12381/3600/{0..9}/0/0",
+// into the paths of every index from start up to and including end, in order.
+// The bounds are decimal indices, like any other path segment.
+// A range of more than MaxPathRange indices is rejected.
+func ExpandPathRange(template string) ([]string, error) {
+	segments := strings.Split(template, "/")
+	at := -1
+	for i, seg := range segments {
+		if !strings.ContainsAny(seg, "{}") {
+			continue
+		}
+		if at >= 0 {
+			return nil, fmt.Errorf("expected one range segment in path template %q, got multiple", template)
+		}
+		at = i
+	}
+	if at < 0 {
+		return nil, fmt.Errorf("expected one range segment in path template %q, got none", template)
+	}
+	start, end, err := parsePathRange(segments[at])
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template %q: %w", template, err)
+	}
+	out := make([]string, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		segments[at] = strconv.FormatUint(i, 10)
+		path := strings.Join(segments, "/")
+		// Only the range segment differs between the paths, so checking the first one is enough.
+		if i == start {
+			if _, err := ParsePath(path); err != nil {
+				return nil, fmt.Errorf("invalid path template %q: %w", template, err)
+			}
+		}
+		out = append(out, path)
+	}
+	return out, nil
+}
+
+// parsePathRange parses a {start..end} range segment.
+func parsePathRange(seg string) (start, end uint64, err error) {
+	inner, ok := strings.CutPrefix(seg, "{")
+	if ok {
+		inner, ok = strings.CutSuffix(inner, "}")
+	}
+	var startStr, endStr string
+	if ok {
+		startStr, endStr, ok = strings.Cut(inner, "..")
+	}
+	if !ok {
+		return 0, 0, fmt.Errorf("expected {start..end} range segment, got %q", seg)
+	}
+	parseBound := func(s string) (uint64, error) {
+		v, err := strconv.ParseUint(s, 10, 32)
+		if err != nil || strconv.FormatUint(v, 10) != s {
+			return 0, fmt.Errorf("invalid range bound %q in segment %q", s, seg)
+		}
+		return v, nil
+	}
+	if start, err = parseBound(startStr); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseBound(endStr); err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("range end %d is before start %d", end, start)
+	}
+	if n := end - start + 1; n > MaxPathRange {
+		return 0, 0, fmt.Errorf("range of %d indices exceeds the maximum of %d", n, MaxPathRange)
+	}
+	return start, end, nil
+}
+
+// ErrPathNotAllowed is returned when a path is not in the DeriveOptions.AllowList.
+var ErrPathNotAllowed = errors.New("path not allowed")
+
+// PathAllowList is a list of path patterns. A pattern is an ERC-2334 path,
+// where any child segment may be "*" to match any index, e.g. "m/12381/3600/*/0/0".
+// Other segments follow the same canonical rules as ParsePath, see Validate.
+type PathAllowList []string
+
+// Validate checks that every pattern is a valid path, with "*" segments substituted.
+// The returned error wraps the PathError of the first invalid pattern.
+func (l PathAllowList) Validate() error {
+	for i, pattern := range l {
+		if _, _, err := parsePathPattern(pattern); err != nil {
+			return fmt.Errorf("invalid allow-list pattern %d %q: %w", i, pattern, err)
+		}
+	}
+	return nil
+}
+
+// Allows checks if the path matches any of the patterns.
+// An invalid pattern matches no path: use Validate to reject it upfront.
+func (l PathAllowList) Allows(path string) bool {
+	indices, err := ParsePath(path)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range l {
+		if matchPathPattern(pattern, indices) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePathPattern parses an allow-list pattern with ParsePath, with every "*" segment substituted by index 0.
+// The wildcard segments are marked in the returned wildcards, aligned with the returned path.
+func parsePathPattern(pattern string) (Path, []bool, error) {
+	segments := strings.Split(pattern, "/")
+	wildcards := make([]bool, 0, len(segments))
+	for i, seg := range segments {
+		if i == 0 {
+			continue
+		}
+		wildcards = append(wildcards, seg == "*")
+		if seg == "*" {
+			segments[i] = "0"
+		}
+	}
+	p, err := ParsePath(strings.Join(segments, "/"))
+	if err != nil {
+		// "*" and "0" have the same length, so only the path needs to be restored for the offsets to hold
+		var pathErr *PathError
+		if errors.As(err, &pathErr) {
+			pathErr.Path = pattern
+		}
+		return nil, nil, err
+	}
+	return p, wildcards, nil
+}
+
+func matchPathPattern(pattern string, indices Path) bool {
+	p, wildcards, err := parsePathPattern(pattern)
+	if err != nil || len(p) != len(indices) {
+		return false
+	}
+	for i, index := range p {
+		if !wildcards[i] && index != indices[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,267 @@
+package bls12_381_hd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	valid := map[string]Path{
+		"m":                  {},
+		"m/0":                {0},
+		"m/12381/3600/0/0/0": {12381, 3600, 0, 0, 0},
+		"m/4294967295":       {4294967295},
+	}
+	for s, expected := range valid {
+		p, err := ParsePath(s)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", s, err)
+		}
+		if len(p) != len(expected) {
+			t.Fatalf("%q: got %v, expected %v", s, p, expected)
+		}
+		for i := range p {
+			if p[i] != expected[i] {
+				t.Fatalf("%q: got %v, expected %v", s, p, expected)
+			}
+		}
+		if p.String() != s {
+			t.Fatalf("got %q, expected %q", p.String(), s)
+		}
+	}
+	invalid := map[string]error{
+		"":             ErrEmptyPath,
+		"m/":           ErrInvalidSegment,
+		"/1":           ErrMissingMaster,
+		"1/2":          ErrMissingMaster,
+		"m//1":         ErrInvalidSegment,
+		"m/m":          ErrUnexpectedMaster,
+		"m/-1":         ErrInvalidSegment,
+		"m/4294967296": ErrIndexOutOfRange,
+		"m/00/1":       ErrInvalidSegment,
+		"m/01":         ErrInvalidSegment,
+		"m/x":          ErrInvalidSegment,
+		"m/ 1":         ErrInvalidSegment,
+		"m/1 ":         ErrInvalidSegment,
+		"m/+1":         ErrInvalidSegment,
+		"m/12381/01/0": ErrInvalidSegment,
+	}
+	for s, expected := range invalid {
+		if _, err := ParsePath(s); !errors.Is(err, expected) {
+			t.Fatalf("%q: expected %v, got %v", s, expected, err)
+		}
+	}
+}
+
+func TestParsePathCanonical(t *testing.T) {
+	for s, reason := range map[string]string{
+		"m/01":  "leading zero",
+		"m/ 1":  "surrounding whitespace",
+		"m/\t1": "surrounding whitespace",
+		"m/+1":  "sign",
+		"m/-1":  "sign",
+	} {
+		_, err := ParsePath(s)
+		if err == nil || !strings.HasSuffix(err.Error(), reason) {
+			t.Fatalf("%q: expected %q error, got %v", s, reason, err)
+		}
+	}
+}
+
+func TestPathChildParent(t *testing.T) {
+	base := Path{12381, 3600}
+	child := base.Child(7)
+	if child.String() != "m/12381/3600/7" {
+		t.Fatalf("unexpected child %s", child)
+	}
+	// the parent must not be modified by appending children
+	other := base.Child(8)
+	if child[2] != 7 || other[2] != 8 || len(base) != 2 {
+		t.Fatal("child paths alias each other")
+	}
+	parent, err := child.Parent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent.String() != base.String() {
+		t.Fatalf("unexpected parent %s", parent)
+	}
+	if _, err := (Path{}).Parent(); err == nil {
+		t.Fatal("expected master node to have no parent")
+	}
+}
+
+func TestSecretKeyFromPath(t *testing.T) {
+	seed := make([]byte, 32)
+	expected, err := SecretKeyFromHD(seed, "m/12381/3600/0/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := SecretKeyFromPath(seed, Path{12381, 3600}.Child(0).Child(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *expected {
+		t.Fatal("keys differ")
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	path, err := ExpandTemplate("m/12381/3600/{}/0/0", 42)
+	if err != nil {
+		t.Fatalf("failed to expand template: %v", err)
+	}
+	if path != "m/12381/3600/42/0/0" {
+		t.Fatalf("unexpected path %q", path)
+	}
+	if _, err := ParsePath(path); err != nil {
+		t.Fatalf("expanded path does not parse: %v", err)
+	}
+	for _, template := range []string{
+		"m/12381/3600/0/0/0",
+		"m/12381/3600/{}/{}/0",
+		"m/12381/3600/x{}/0/0",
+		"{}/12381/3600/0/0",
+	} {
+		if _, err := ExpandTemplate(template, 1); err == nil {
+			t.Fatalf("expected template %q to be rejected", template)
+		}
+	}
+}
+
+func TestExpandPathRange(t *testing.T) {
+	paths, err := ExpandPathRange("m/12381/3600/{8..10}/0/0")
+	if err != nil {
+		t.Fatalf("failed to expand template: %v", err)
+	}
+	expected := []string{"m/12381/3600/8/0/0", "m/12381/3600/9/0/0", "m/12381/3600/10/0/0"}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Fatalf("unexpected paths %q", paths)
+	}
+	if paths, err := ExpandPathRange("m/{4294967295..4294967295}"); err != nil || len(paths) != 1 || paths[0] != "m/4294967295" {
+		t.Fatalf("expected max index range to expand to one path, got %q, %v", paths, err)
+	}
+	for _, template := range []string{
+		"m/12381/3600/0/0/0",
+		"m/12381/3600/{0..1}/{0..1}/0",
+		"m/12381/3600/{2..1}/0/0",
+		"m/12381/3600/{0..65536}/0/0",
+		"m/12381/3600/{0..4294967296}/0/0",
+		"m/12381/3600/{01..2}/0/0",
+		"m/12381/3600/{0..}/0/0",
+		"m/12381/3600/{0-1}/0/0",
+		"m/12381/3600/x{0..1}/0/0",
+		"m/12381/3600/{}/0/0",
+		"{0..1}/12381/3600/0/0",
+		"m/12381/x/{0..1}/0",
+	} {
+		if _, err := ExpandPathRange(template); err == nil {
+			t.Fatalf("expected template %q to be rejected", template)
+		}
+	}
+	if paths, err := ExpandPathRange("m/12381/3600/{0..65535}/0/0"); err != nil || len(paths) != MaxPathRange {
+		t.Fatalf("expected range of MaxPathRange indices to expand, got %d paths, %v", len(paths), err)
+	}
+}
+
+func TestPathError(t *testing.T) {
+	_, err := SecretKeyFromHD(make([]byte, 32), "m/12381/abc/0")
+	var pathErr *PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("expected path error, got %v", err)
+	}
+	if pathErr.Segment != 2 || pathErr.Offset != 8 {
+		t.Fatalf("unexpected segment %d and offset %d", pathErr.Segment, pathErr.Offset)
+	}
+	if msg := err.Error(); msg != `invalid child node at segment 2, value "abc": strconv.ParseUint: parsing "abc": invalid syntax` {
+		t.Fatalf("unexpected error message: %s", msg)
+	}
+	expected := "m/12381/abc/0\n        ^^^"
+	if got := pathErr.Indicator(); got != expected {
+		t.Fatalf("unexpected indicator:\n%s\nexpected:\n%s", got, expected)
+	}
+	// an empty segment still gets a visible caret
+	_, err = ParsePath("m//0")
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("expected path error, got %v", err)
+	}
+	if got := pathErr.Indicator(); got != "m//0\n  ^" {
+		t.Fatalf("unexpected indicator:\n%s", got)
+	}
+}
+
+func TestPathAllowList(t *testing.T) {
+	allow := PathAllowList{"m/12381/3600/*/0/0", "m/12381/3600/7/0"}
+	for path, allowed := range map[string]bool{
+		"m/12381/3600/0/0/0":  true,
+		"m/12381/3600/42/0/0": true,
+		"m/12381/3600/7/0":    true,
+		"m/12381/3600/8/0":    false,
+		"m/12381/3600/0/0":    false,
+		"m/12381/3600/0/0/1":  false,
+		"m":                   false,
+		"m/x":                 false,
+	} {
+		if got := allow.Allows(path); got != allowed {
+			t.Fatalf("path %q: got %v, expected %v", path, got, allowed)
+		}
+	}
+	opts := &DeriveOptions{AllowList: allow}
+	if _, err := SecretKeyFromHDWithOptions(context.Background(), make([]byte, 32), "m/12381/3600/7/0", opts); err != nil {
+		t.Fatalf("expected allowed path to derive: %v", err)
+	}
+	// a cancelled context fails at the first derivation step, so the allow-list must be checked before that
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := SecretKeyFromHDWithOptions(ctx, make([]byte, 32), "m/12381/3600/8/0", opts)
+	if !errors.Is(err, ErrPathNotAllowed) {
+		t.Fatalf("expected path to be rejected, got %v", err)
+	}
+	if err := allow.Validate(); err != nil {
+		t.Fatalf("expected valid patterns: %v", err)
+	}
+	// non-canonical segments must not match, and make the allow-list invalid
+	for _, pattern := range []string{"m/12381/3600/01/0", "m/12381/3600/+1/0", "m/12381/3600/1*/0", "m/12381/3600/*/", "M/*", "m/4294967296"} {
+		bad := PathAllowList{pattern}
+		if bad.Allows("m/12381/3600/1/0") {
+			t.Fatalf("pattern %q: expected no match", pattern)
+		}
+		var pathErr *PathError
+		if err := bad.Validate(); !errors.As(err, &pathErr) {
+			t.Fatalf("pattern %q: expected path error, got %v", pattern, err)
+		}
+		if pathErr.Path != pattern || !strings.Contains(pathErr.Indicator(), pattern) {
+			t.Fatalf("pattern %q: expected the error to show the original pattern, got %q", pattern, pathErr.Indicator())
+		}
+		opts := &DeriveOptions{AllowList: bad}
+		if _, err := SecretKeyFromHDWithOptions(context.Background(), make([]byte, 32), "m/12381/3600/1/0", opts); err == nil || errors.Is(err, ErrPathNotAllowed) {
+			t.Fatalf("pattern %q: expected invalid allow-list error, got %v", pattern, err)
+		}
+	}
+}
+
+func FuzzParsePath(f *testing.F) {
+	for _, s := range []string{
+		"m", "m/0", "m/12381/3600/0/0", "m/12381/3600/1/0/0", "m/12381/3600/123/42", "m/4294967295",
+		"", "m/", "m//1", "m/-1", "m/+1", "m/ 1", "m/4294967296", "/1", "m/0x1", "m/01", "m/m", "M/1", "m/1/",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		p, err := ParsePath(s)
+		if err != nil {
+			var pathErr *PathError
+			if !errors.As(err, &pathErr) {
+				t.Fatalf("expected path error, got %v", err)
+			}
+			_ = pathErr.Indicator()
+			return
+		}
+		// valid paths are canonical: formatting gives back the exact input
+		if got := p.String(); got != s {
+			t.Fatalf("path %q formats as %q", s, got)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package bls12_381_hd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// SeedPEMType is the PEM block type used by SeedToPEM and SeedFromPEM.
+const SeedPEMType = "BLS12-381 HD SEED"
+
+// SeedToPEM encodes the seed as a PEM block of type SeedPEMType.
+func SeedToPEM(seed Seed) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: SeedPEMType, Bytes: seed})
+}
+
+// SeedFromPEM decodes a seed from the first PEM block in data, which must be of type SeedPEMType.
+func SeedFromPEM(data []byte) (Seed, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type != SeedPEMType {
+		return nil, fmt.Errorf("unexpected PEM block type %q, expected %q", block.Type, SeedPEMType)
+	}
+	if len(block.Bytes) < 32 {
+		return nil, ErrSeedTooShort
+	}
+	return Seed(block.Bytes), nil
+}
+
+// SeedChecksum returns a short fingerprint of the seed: the first 4 bytes of SHA256(seed), hex encoded.
+// This can be used to check that a backup of the seed was copied correctly,
+// without revealing the seed itself.
+func SeedChecksum(seed Seed) string {
+	return hex.EncodeToString(SHA256(seed)[:4])
+}
+
+// SeedKind is a hint about what kind of input a seed likely is. See ClassifySeed.
+type SeedKind uint8
+
+const (
+	// SeedOther is any length other than 32 or 64 bytes.
+	SeedOther SeedKind = iota
+	// SeedEntropy32 is 32 bytes: likely raw entropy, e.g. mnemonic entropy instead of the BIP-39 seed.
+	SeedEntropy32
+	// SeedBIP39 is 64 bytes: the output size of the BIP-39 mnemonic-to-seed function.
+	SeedBIP39
+)
+
+func (k SeedKind) String() string {
+	switch k {
+	case SeedEntropy32:
+		return "entropy-32"
+	case SeedBIP39:
+		return "bip39-seed-64"
+	default:
+		return "other"
+	}
+}
+
+// ClassifySeed hints at what kind of input the seed is, based only on its length.
+// This is a heuristic: it helps to catch mistakes like passing 32 bytes of mnemonic entropy
+// where a 64-byte BIP-39 seed was intended, which silently derives a different key tree.
+func ClassifySeed(seed []byte) SeedKind {
+	switch len(seed) {
+	case 32:
+		return SeedEntropy32
+	case 64:
+		return SeedBIP39
+	default:
+		return SeedOther
+	}
+}
+
+// symmetricKeySalt separates symmetric keys from the BLS key tree, which uses "BLS-SIG-KEYGEN-SALT-".
+const symmetricKeySalt = "BLS12-381-HD-SYMMETRIC-KEY-SALT-"
+
+// DeriveSymmetricKey derives length bytes of symmetric key material from the seed,
+// e.g. to encrypt local application data with a key that is recoverable from the same backup.
+//
+// This uses HKDF-SHA256 with a salt distinct from the ERC-2333 key generation,
+// and info to separate different uses of the same seed. The output is not a BLS secret key.
+func DeriveSymmetricKey(seed Seed, info string, length int) ([]byte, error) {
+	if len(seed) < 32 {
+		return nil, ErrSeedTooShort
+	}
+	if length <= 0 || length > 255*sha256.Size {
+		return nil, fmt.Errorf("key length %d out of range", length)
+	}
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, seed, []byte(symmetricKeySalt), []byte(info)), out); err != nil {
+		return nil, fmt.Errorf("failed to read HKDF output: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,99 @@
+package bls12_381_hd
+
+import (
+	"bytes"
+	"encoding/pem"
+	"testing"
+)
+
+func TestSeedPEM(t *testing.T) {
+	seed := make(Seed, 64)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	data := SeedToPEM(seed)
+	got, err := SeedFromPEM(data)
+	if err != nil {
+		t.Fatalf("failed to decode seed: %v", err)
+	}
+	if !bytes.Equal(got, seed) {
+		t.Fatalf("seeds differ:\n%x < got\n%x < expected\n", got, seed)
+	}
+	wrongType := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: seed})
+	if _, err := SeedFromPEM(wrongType); err == nil {
+		t.Fatal("expected wrong PEM type to be rejected")
+	}
+	if _, err := SeedFromPEM([]byte("not a pem")); err == nil {
+		t.Fatal("expected non-PEM data to be rejected")
+	}
+}
+
+func TestSeedChecksum(t *testing.T) {
+	seed := make(Seed, 32)
+	// first 4 bytes of SHA256 of 32 zero bytes
+	if got := SeedChecksum(seed); got != "66687aad" {
+		t.Fatalf("unexpected checksum %q", got)
+	}
+	other := make(Seed, 32)
+	other[31] = 1
+	if SeedChecksum(other) == SeedChecksum(seed) {
+		t.Fatal("expected different seeds to have different checksums")
+	}
+}
+
+func TestClassifySeed(t *testing.T) {
+	testCases := []struct {
+		Length int
+		Kind   SeedKind
+	}{
+		{0, SeedOther},
+		{16, SeedOther},
+		{32, SeedEntropy32},
+		{48, SeedOther},
+		{64, SeedBIP39},
+		{65, SeedOther},
+	}
+	for _, tc := range testCases {
+		if got := ClassifySeed(make([]byte, tc.Length)); got != tc.Kind {
+			t.Fatalf("length %d: got %s, expected %s", tc.Length, got, tc.Kind)
+		}
+	}
+}
+
+func TestDeriveSymmetricKey(t *testing.T) {
+	seed := make(Seed, 32)
+	a, err := DeriveSymmetricKey(seed, "app-data", 32)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+	b, err := DeriveSymmetricKey(seed, "app-data", 32)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Fatal("expected derivation to be deterministic")
+	}
+	c, err := DeriveSymmetricKey(seed, "other-data", 32)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+	if bytes.Equal(a, c) {
+		t.Fatal("expected different info to derive a different key")
+	}
+	masterSK, err := SecretKeyFromHD(seed, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, masterSK[:]) {
+		t.Fatal("expected symmetric key to differ from the BLS master key")
+	}
+	if long, err := DeriveSymmetricKey(seed, "app-data", 64); err != nil || !bytes.Equal(long[:32], a) {
+		t.Fatalf("expected longer output to extend the same key stream: %v", err)
+	}
+	if _, err := DeriveSymmetricKey(seed, "app-data", 0); err == nil {
+		t.Fatal("expected zero length to be rejected")
+	}
+	if _, err := DeriveSymmetricKey(seed[:16], "app-data", 32); err == nil {
+		t.Fatal("expected short seed to be rejected")
+	}
+}
@@ -0,0 +1,224 @@
+package bls12_381_hd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrSKSetChecksum is returned when a key in an encrypted SK set fails the checksum,
+// which typically means the password is wrong.
+var ErrSKSetChecksum = errors.New("sk set checksum mismatch, wrong password?")
+
+const skSetVersion = 1
+
+// scrypt parameters, matching the EIP-2335 keystore defaults.
+const (
+	scryptN     = 1 << 18
+	scryptR     = 8
+	scryptP     = 1
+	scryptDKLen = 32
+)
+
+// Upper bounds on the scrypt cost of a decrypted keystore or SK set, as these are untrusted input.
+// scrypt uses 128 * N * r bytes of memory, 256MB at the maximum, and its CPU time grows with N * r * p.
+const (
+	scryptMaxN = 1 << 18
+	scryptMaxR = 8
+	scryptMaxP = 4
+)
+
+type hexBytes []byte
+
+func (b hexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+func (b *hexBytes) UnmarshalText(text []byte) error {
+	out, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	*b = out
+	return nil
+}
+
+type scryptParams struct {
+	DKLen int      `json:"dklen"`
+	N     int      `json:"n"`
+	R     int      `json:"r"`
+	P     int      `json:"p"`
+	Salt  hexBytes `json:"salt"`
+}
+
+func newScryptParams(rng io.Reader) (*scryptParams, error) {
+	salt := make([]byte, 32)
+	if _, err := io.ReadFull(rng, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return &scryptParams{DKLen: scryptDKLen, N: scryptN, R: scryptR, P: scryptP, Salt: salt}, nil
+}
+
+func (p *scryptParams) deriveKey(password []byte) ([]byte, error) {
+	if p.DKLen != 32 {
+		return nil, fmt.Errorf("unsupported scrypt dklen %d, expected 32", p.DKLen)
+	}
+	if p.N < 2 || p.N > scryptMaxN || p.N&(p.N-1) != 0 {
+		return nil, fmt.Errorf("unsupported scrypt n %d, expected a power of two up to %d", p.N, scryptMaxN)
+	}
+	if p.R < 1 || p.R > scryptMaxR {
+		return nil, fmt.Errorf("unsupported scrypt r %d, expected 1 to %d", p.R, scryptMaxR)
+	}
+	if p.P < 1 || p.P > scryptMaxP {
+		return nil, fmt.Errorf("unsupported scrypt p %d, expected 1 to %d", p.P, scryptMaxP)
+	}
+	return scrypt.Key(password, p.Salt, p.N, p.R, p.P, p.DKLen)
+}
+
+// aes128CTR en/decrypts data with the first 16 bytes of the derived key.
+func aes128CTR(dk []byte, iv []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(dk[:16])
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, fmt.Errorf("expected %d byte iv, got %d", block.BlockSize(), len(iv))
+	}
+	out := make([]byte, len(data))
+	cipher.NewCTR(block, iv).XORKeyStream(out, data)
+	return out, nil
+}
+
+// checksum computes SHA256 over the second half of the derived key and the cipher text.
+func checksum(dk []byte, cipherText []byte) [32]byte {
+	return [32]byte(SHA256(append(append(make([]byte, 0, 16+len(cipherText)), dk[16:32]...), cipherText...)))
+}
+
+type skSetKDF struct {
+	Function string        `json:"function"`
+	Params   *scryptParams `json:"params"`
+}
+
+type skSetEntry struct {
+	Path       string   `json:"path"`
+	IV         hexBytes `json:"iv"`
+	CipherText hexBytes `json:"ciphertext"`
+	Checksum   hexBytes `json:"checksum"`
+}
+
+type skSet struct {
+	Version int          `json:"version"`
+	KDF     skSetKDF     `json:"kdf"`
+	Keys    []skSetEntry `json:"keys"`
+}
+
+// EncryptSKSet encrypts a set of secret keys, with their derivation paths,
+// into a single password-protected JSON document.
+//
+// The password is stretched with a single scrypt invocation (EIP-2335 default parameters),
+// and each key is encrypted with AES-128-CTR under its own random IV, with a SHA256 checksum per key.
+// The password is normalized as in EIP-2335, and every key must be in [1, r).
+// This is not an EIP-2335 keystore: it is only readable with DecryptSKSet.
+func EncryptSKSet(keys []*SK, paths []string, password string) ([]byte, error) {
+	return encryptSKSet(keys, paths, password, rand.Reader)
+}
+
+func encryptSKSet(keys []*SK, paths []string, password string, rng io.Reader) ([]byte, error) {
+	if len(keys) != len(paths) {
+		return nil, fmt.Errorf("got %d keys but %d paths", len(keys), len(paths))
+	}
+	for i, key := range keys {
+		if !key.InRange() {
+			return nil, fmt.Errorf("invalid secret key %d: %w", i, ErrSKOutOfRange)
+		}
+	}
+	params, err := newScryptParams(rng)
+	if err != nil {
+		return nil, err
+	}
+	pw := keystorePassword(password)
+	defer zero(pw)
+	dk, err := params.deriveKey(pw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive decryption key: %w", err)
+	}
+	defer zero(dk)
+	out := skSet{
+		Version: skSetVersion,
+		KDF:     skSetKDF{Function: "scrypt", Params: params},
+		Keys:    make([]skSetEntry, len(keys)),
+	}
+	for i, key := range keys {
+		iv := make([]byte, 16)
+		if _, err := io.ReadFull(rng, iv); err != nil {
+			return nil, fmt.Errorf("failed to generate iv for key %d: %w", i, err)
+		}
+		sk32 := I2OSP32((*big.Int)(key))
+		cipherText, err := aes128CTR(dk, iv, sk32[:])
+		zero(sk32[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt key %d: %w", i, err)
+		}
+		sum := checksum(dk, cipherText)
+		out.Keys[i] = skSetEntry{Path: paths[i], IV: iv, CipherText: cipherText, Checksum: sum[:]}
+	}
+	return json.Marshal(&out)
+}
+
+// DecryptSKSet decrypts a document produced by EncryptSKSet,
+// returning the secret keys and their paths in the original order.
+// ErrSKSetChecksum is returned if the password is wrong.
+func DecryptSKSet(data []byte, password string) ([]*SK, []string, error) {
+	var in skSet
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode sk set: %w", err)
+	}
+	if in.Version != skSetVersion {
+		return nil, nil, fmt.Errorf("unsupported sk set version %d", in.Version)
+	}
+	if in.KDF.Function != "scrypt" || in.KDF.Params == nil {
+		return nil, nil, fmt.Errorf("unsupported kdf %q", in.KDF.Function)
+	}
+	pw := keystorePassword(password)
+	defer zero(pw)
+	dk, err := in.KDF.Params.deriveKey(pw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive decryption key: %w", err)
+	}
+	defer zero(dk)
+	keys := make([]*SK, len(in.Keys))
+	paths := make([]string, len(in.Keys))
+	for i, entry := range in.Keys {
+		if len(entry.CipherText) != 32 {
+			return nil, nil, fmt.Errorf("key %d: expected 32 byte cipher text, got %d", i, len(entry.CipherText))
+		}
+		sum := checksum(dk, entry.CipherText)
+		if len(entry.Checksum) != 32 || !ctEqual32(&sum, (*[32]byte)(entry.Checksum)) {
+			return nil, nil, fmt.Errorf("key %d: %w", i, ErrSKSetChecksum)
+		}
+		sk32, err := aes128CTR(dk, entry.IV, entry.CipherText)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt key %d: %w", i, err)
+		}
+		sk := (*SK)(osToIP(sk32))
+		zero(sk32)
+		if !sk.InRange() {
+			sk.Wipe()
+			for _, key := range keys[:i] {
+				key.Wipe()
+			}
+			return nil, nil, fmt.Errorf("decrypted key %d is not a valid secret key: %w", i, ErrSKOutOfRange)
+		}
+		keys[i] = sk
+		paths[i] = entry.Path
+	}
+	return keys, paths, nil
+}
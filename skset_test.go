@@ -0,0 +1,89 @@
+package bls12_381_hd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestSKSetRoundTrip(t *testing.T) {
+	seed := make([]byte, 32)
+	var keys []*SK
+	var paths []string
+	for i := 0; i < 4; i++ {
+		path := fmt.Sprintf("m/12381/3600/%d/0/0", i)
+		key, err := SecretKeyFromHD(seed, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, (*SK)(osToIP(key[:])))
+		paths = append(paths, path)
+	}
+	data, err := EncryptSKSet(keys, paths, "testpassword")
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	gotKeys, gotPaths, err := DecryptSKSet(data, "testpassword")
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+	if len(gotKeys) != len(keys) || len(gotPaths) != len(paths) {
+		t.Fatalf("expected %d keys, got %d keys and %d paths", len(keys), len(gotKeys), len(gotPaths))
+	}
+	for i := range keys {
+		if (*big.Int)(keys[i]).Cmp((*big.Int)(gotKeys[i])) != 0 {
+			t.Fatalf("key %d differs", i)
+		}
+		if paths[i] != gotPaths[i] {
+			t.Fatalf("path %d: got %q, expected %q", i, gotPaths[i], paths[i])
+		}
+	}
+	if _, _, err := DecryptSKSet(data, "wrongpassword"); !errors.Is(err, ErrSKSetChecksum) {
+		t.Fatalf("expected checksum error for wrong password, got %v", err)
+	}
+	if _, err := EncryptSKSet(keys, paths[:1], "testpassword"); err == nil {
+		t.Fatal("expected mismatched keys and paths to be rejected")
+	}
+	costly := bytes.Replace(data, []byte(`"n":262144`), []byte(`"n":2147483648`), 1)
+	if _, _, err := DecryptSKSet(costly, "testpassword"); err == nil || errors.Is(err, ErrSKSetChecksum) {
+		t.Fatalf("expected excessive scrypt cost to be rejected, got %v", err)
+	}
+}
+
+func TestSKSetInvalidKeys(t *testing.T) {
+	huge := (*SK)(new(big.Int).Lsh(big.NewInt(1), 256))
+	for _, key := range []*SK{nil, (*SK)(big.NewInt(0)), (*SK)(big.NewInt(-1)), (*SK)(new(big.Int).Set(r)), huge} {
+		if _, err := EncryptSKSet([]*SK{key}, []string{"m"}, "testpassword"); !errors.Is(err, ErrSKOutOfRange) {
+			t.Fatalf("expected key %v to be rejected, got %v", key, err)
+		}
+	}
+	data, err := EncryptSKSet([]*SK{(*SK)(big.NewInt(1))}, []string{"m"}, "testpassword")
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	// replace the key with a zero key, under a valid checksum
+	var set skSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatal(err)
+	}
+	dk, err := set.KDF.Params.deriveKey(keystorePassword("testpassword"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherText, err := aes128CTR(dk, set.Keys[0].IV, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := checksum(dk, cipherText)
+	set.Keys[0].CipherText, set.Keys[0].Checksum = cipherText, sum[:]
+	data, err = json.Marshal(&set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := DecryptSKSet(data, "testpassword"); !errors.Is(err, ErrSKOutOfRange) {
+		t.Fatalf("expected zero key to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package bls12_381_hd
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Stats summarizes a batch of derived child keys. See DerivationStats.
+type Stats struct {
+	// Count is the number of derived keys.
+	Count int
+	// Collisions is the number of keys that were equal to an earlier key in the batch.
+	Collisions int
+	// MinBitLen and MaxBitLen are the smallest and largest bit lengths of the derived keys.
+	// The keys themselves are secret, and not part of the stats.
+	MinBitLen, MaxBitLen int
+	// UpperHalf is the number of keys >= r/2. For an unbiased derivation this is close to Count/2.
+	UpperHalf int
+}
+
+// DerivationStats derives the children 0 ... count-1 of the master key of the seed,
+// and reports basic statistics about them.
+//
+// This is a diagnostic, e.g. to sanity-check a port or a custom hash variant,
+// and not a cryptographic validation of the output distribution.
+func DerivationStats(seed Seed, count int) (Stats, error) {
+	if count <= 0 {
+		return Stats{}, errors.New("count must be positive")
+	}
+	if uint64(count) > 1<<32 {
+		return Stats{}, fmt.Errorf("count %d exceeds index range", count)
+	}
+	masterSK, err := DeriveMasterSK(seed)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to derive master SK: %w", err)
+	}
+	halfR := new(big.Int).Rsh(r, 1)
+	seen := make(map[[32]byte]struct{}, count)
+	stats := Stats{Count: count, MinBitLen: -1}
+	for i := 0; i < count; i++ {
+		sk, err := DeriveChildSK(masterSK, uint32(i))
+		if err != nil {
+			return Stats{}, fmt.Errorf("failed to derive child %d: %w", i, err)
+		}
+		v := (*big.Int)(sk)
+		key := I2OSP32(v)
+		if _, ok := seen[key]; ok {
+			stats.Collisions += 1
+		}
+		seen[key] = struct{}{}
+		if n := v.BitLen(); stats.MinBitLen < 0 || n < stats.MinBitLen {
+			stats.MinBitLen = n
+		}
+		if n := v.BitLen(); n > stats.MaxBitLen {
+			stats.MaxBitLen = n
+		}
+		if v.Cmp(halfR) >= 0 {
+			stats.UpperHalf += 1
+		}
+		sk.Wipe()
+	}
+	return stats, nil
+}
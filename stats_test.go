@@ -0,0 +1,41 @@
+package bls12_381_hd
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDerivationStats(t *testing.T) {
+	seed := make([]byte, 32)
+	stats, err := DerivationStats(seed, 32)
+	if err != nil {
+		t.Fatalf("failed to compute stats: %v", err)
+	}
+	if stats.Count != 32 {
+		t.Fatalf("expected count 32, got %d", stats.Count)
+	}
+	if stats.Collisions != 0 {
+		t.Fatalf("expected no collisions, got %d", stats.Collisions)
+	}
+	// r is 255 bits, and 32 keys all shorter than 250 bits would be a 2**-160 event
+	if stats.MinBitLen > stats.MaxBitLen || stats.MaxBitLen > 255 || stats.MaxBitLen < 250 {
+		t.Fatalf("unexpected bit lengths: min %d, max %d", stats.MinBitLen, stats.MaxBitLen)
+	}
+	// with 32 samples, all in one half would be a 2**-31 event
+	if stats.UpperHalf == 0 || stats.UpperHalf == stats.Count {
+		t.Fatalf("keys are not spread across the range: %d of %d in upper half", stats.UpperHalf, stats.Count)
+	}
+}
+
+func TestDerivationStatsCount(t *testing.T) {
+	seed := make([]byte, 32)
+	if _, err := DerivationStats(seed, 0); err == nil {
+		t.Fatal("expected zero count to be rejected")
+	}
+	// the count is rejected before any derivation, and before allocating for it
+	if math.MaxInt > 1<<32 {
+		if _, err := DerivationStats(seed, math.MaxInt); err == nil {
+			t.Fatal("expected count beyond the index range to be rejected")
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package bls12_381_hd
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// TestVector holds the intermediate values of an ERC-2333 master and child key derivation,
+// formatted like the test-vectors of the ERC: byte strings as 0x-prefixed hex,
+// secret keys as decimal integers.
+//
+// https://eips.ethereum.org/EIPS/eip-2333#test-cases
+type TestVector struct {
+	Seed                string `json:"seed"`
+	MasterSK            string `json:"master_SK"`
+	ChildIndex          uint32 `json:"child_index"`
+	CompressedLamportPK string `json:"compressed_lamport_PK"`
+	ChildSK             string `json:"child_SK"`
+}
+
+// GenerateTestVector derives the master key of the seed and its child at the given index,
+// and returns all intermediate values, to cross-validate other ERC-2333 implementations.
+func GenerateTestVector(seed Seed, index uint32) (TestVector, error) {
+	masterSK, err := DeriveMasterSK(seed)
+	if err != nil {
+		return TestVector{}, fmt.Errorf("failed to derive master SK: %w", err)
+	}
+	compressedLamportPK, err := ParentSKToLamportPK(masterSK, index)
+	if err != nil {
+		return TestVector{}, fmt.Errorf("failed to compute lamport PK: %w", err)
+	}
+	childSK, err := DeriveChildSK(masterSK, index)
+	if err != nil {
+		return TestVector{}, fmt.Errorf("failed to derive child SK: %w", err)
+	}
+	return TestVector{
+		Seed:                "0x" + hex.EncodeToString(seed),
+		MasterSK:            (*big.Int)(masterSK).String(),
+		ChildIndex:          index,
+		CompressedLamportPK: "0x" + hex.EncodeToString(compressedLamportPK[:]),
+		ChildSK:             (*big.Int)(childSK).String(),
+	}, nil
+}
+
+// DifferentialCheck derives keys for n random seeds and paths, and compares them against the
+// reference implementation. It returns an error describing the inputs of the first divergence.
+//
+// The paths have up to 5 child nodes with random indices, and the seeds are 32 or 64 bytes.
+// This lets other projects test their own implementation against this package (or vice versa).
+func DifferentialCheck(n int, reference func(seed []byte, path string) ([32]byte, error)) error {
+	var rnd [8]byte
+	for i := 0; i < n; i++ {
+		if _, err := rand.Read(rnd[:]); err != nil {
+			return fmt.Errorf("failed to read randomness: %w", err)
+		}
+		seed := make([]byte, 32+32*int(rnd[0]&1))
+		if _, err := rand.Read(seed); err != nil {
+			return fmt.Errorf("failed to read randomness: %w", err)
+		}
+		depth := int(rnd[1] % 6)
+		path := "m"
+		for j := 0; j < depth; j++ {
+			var index [4]byte
+			if _, err := rand.Read(index[:]); err != nil {
+				return fmt.Errorf("failed to read randomness: %w", err)
+			}
+			path += "/" + strconv.FormatUint(uint64(binary.BigEndian.Uint32(index[:])), 10)
+		}
+		expected, err := SecretKeyFromHD(seed, path)
+		if err != nil {
+			return fmt.Errorf("case %d: failed to derive key for seed 0x%x, path %q: %w", i, seed, path, err)
+		}
+		got, err := reference(seed, path)
+		if err != nil {
+			return fmt.Errorf("case %d: reference failed for seed 0x%x, path %q: %w", i, seed, path, err)
+		}
+		if got != *expected {
+			return fmt.Errorf("case %d: divergence for seed 0x%x, path %q: reference 0x%x, expected 0x%x",
+				i, seed, path, got[:], expected[:])
+		}
+	}
+	return nil
+}
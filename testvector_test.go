@@ -0,0 +1,66 @@
+package bls12_381_hd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestGenerateTestVector(t *testing.T) {
+	for i, tc := range erc2333TestCases {
+		t.Run(fmt.Sprintf("case_%d", i), func(t *testing.T) {
+			seed, err := hex.DecodeString(strings.TrimPrefix(tc.Seed, "0x"))
+			if err != nil {
+				t.Fatalf("failed to decode test seed: %v", err)
+			}
+			vec, err := GenerateTestVector(seed, tc.ChildIndex)
+			if err != nil {
+				t.Fatalf("failed to generate test vector: %v", err)
+			}
+			if vec.Seed != strings.ToLower(tc.Seed) {
+				t.Fatalf("got seed %s but expected %s", vec.Seed, tc.Seed)
+			}
+			if vec.MasterSK != tc.MasterSK {
+				t.Fatalf("got master SK %s but expected %s", vec.MasterSK, tc.MasterSK)
+			}
+			if vec.ChildSK != tc.ChildSK {
+				t.Fatalf("got child SK %s but expected %s", vec.ChildSK, tc.ChildSK)
+			}
+			data, err := json.Marshal(vec)
+			if err != nil {
+				t.Fatalf("failed to encode test vector: %v", err)
+			}
+			var decoded TestVector
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("failed to decode test vector: %v", err)
+			}
+			if decoded != vec {
+				t.Fatalf("test vector changed in JSON round-trip: %s", data)
+			}
+		})
+	}
+}
+
+func TestDifferentialCheck(t *testing.T) {
+	self := func(seed []byte, path string) ([32]byte, error) {
+		key, err := SecretKeyFromHD(seed, path)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		return *key, nil
+	}
+	if err := DifferentialCheck(4, self); err != nil {
+		t.Fatalf("expected no divergence against itself: %v", err)
+	}
+	broken := func(seed []byte, path string) ([32]byte, error) {
+		key, err := self(seed, path)
+		key[31] ^= 1
+		return key, err
+	}
+	err := DifferentialCheck(1, broken)
+	if err == nil || !strings.Contains(err.Error(), "divergence for seed 0x") {
+		t.Fatalf("expected divergence to be reported, got %v", err)
+	}
+}
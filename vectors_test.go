@@ -0,0 +1,88 @@
+package bls12_381_hd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+type deriveVector struct {
+	Source  string `json:"source"`
+	Seed    string `json:"seed"`
+	Path    string `json:"path"`
+	KeyInfo string `json:"key_info"`
+	Key     string `json:"key"`
+}
+
+type hkdfModRVector struct {
+	Source  string `json:"source"`
+	IKM     string `json:"ikm"`
+	KeyInfo string `json:"key_info"`
+	SK      string `json:"sk"`
+}
+
+// testVectors is the format of testdata/vectors.json.
+//
+// The published EIP-2333 and ERC-2334 vectors are inlined in erc2333_test.go and erc2334_test.go,
+// and are not repeated here. This file holds regression vectors, computed with this package,
+// for what those do not cover: eth2 paths from a spec seed, the maximum index, and non-empty key_info.
+// They only catch changes in output, and do not cross-check other implementations.
+// Vectors from other implementations, e.g. staking-deposit-cli or ethdo,
+// can be appended with their source, once checked against it.
+type testVectors struct {
+	Derive   []deriveVector   `json:"derive"`
+	HKDFModR []hkdfModRVector `json:"hkdf_mod_r"`
+}
+
+func loadVectors(t *testing.T, path string) *testVectors {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read test vectors: %v", err)
+	}
+	var out testVectors
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("failed to decode test vectors: %v", err)
+	}
+	return &out
+}
+
+func decodeVectorHex(t *testing.T, s string) []byte {
+	t.Helper()
+	out, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", s, err)
+	}
+	return out
+}
+
+func TestVectors(t *testing.T) {
+	vectors := loadVectors(t, "testdata/vectors.json")
+	if len(vectors.Derive) == 0 || len(vectors.HKDFModR) == 0 {
+		t.Fatal("expected test vectors")
+	}
+	for _, v := range vectors.Derive {
+		t.Run(v.Source+"/"+v.Path, func(t *testing.T) {
+			key, err := SecretKeyFromHDWithKeyInfo(decodeVectorHex(t, v.Seed), v.Path, v.KeyInfo)
+			if err != nil {
+				t.Fatalf("failed to derive key: %v", err)
+			}
+			if got := "0x" + hex.EncodeToString(key[:]); got != v.Key {
+				t.Fatalf("got %s but expected %s", got, v.Key)
+			}
+		})
+	}
+	for i, v := range vectors.HKDFModR {
+		t.Run(v.Source+"/hkdf_mod_r", func(t *testing.T) {
+			sk, err := HKDFModR(decodeVectorHex(t, v.IKM), v.KeyInfo)
+			if err != nil {
+				t.Fatalf("vector %d: %v", i, err)
+			}
+			if got := "0x" + sk.Hex(); got != v.SK {
+				t.Fatalf("vector %d: got %s but expected %s", i, got, v.SK)
+			}
+		})
+	}
+}